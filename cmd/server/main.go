@@ -1,6 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
 	"reisen-be/internal/config"
 	"reisen-be/internal/controller"
 	"reisen-be/internal/filesystem"
@@ -9,7 +15,9 @@ import (
 	"reisen-be/internal/query"
 	"reisen-be/internal/repository"
 	"reisen-be/internal/service"
+	"reisen-be/internal/service/judge"
 	"reisen-be/internal/websocket"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -31,13 +39,34 @@ func main() {
 	if err := db.AutoMigrate(&model.User{}); err != nil {
 		panic("failed to migrate database")
 	}
+	if err := service.NewGormMigrationService(db).RunMigrations(); err != nil {
+		panic("failed to migrate database")
+	}
+	// Load languages
+	languages, err := config.LoadLanguages(cfg.Judge.LanguageFile)
+	if err != nil {
+		panic("failed to load languages: " + err.Error())
+	}
+
 	// Initialize websockets
 	submissionWs := websocket.NewSubmissionWs(100 * time.Millisecond)
+	customTestWs := websocket.NewCustomTestWs(10 * time.Minute)
 
 	// Initialize filesystems
 	problemFilesystem := filesystem.NewProblemFilesystem("/var/problemset")
 	imageFilesystem := filesystem.NewImageFilesystem("/var/www/reisen/uploads/images")
 
+	// Initialize sandboxes
+	sandboxPool := judge.NewSandboxPool()
+	for _, endpoint := range cfg.Sandbox.Endpoints {
+		sandboxPool.Add(judge.NewGoJudgeClient(endpoint.URL), endpoint.Concurrency)
+	}
+	// 沙箱通过主机路径读取题目数据，无法读取探测文件的后端视为不健康
+	if err := sandboxPool.SetMountProbe(problemFilesystem.GetSandboxProbePath()); err != nil {
+		log.Printf("Failed to write sandbox mount probe: %v", err)
+	}
+	sandboxPool.StartHealthCheck(context.Background(), cfg.Sandbox.HealthInterval)
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	problemRepo := repository.NewProblemRepository(db)
@@ -46,6 +75,8 @@ func main() {
 	rankingRepo := repository.NewRankingRepository(db)
 	contestRepo := repository.NewContestRepository(db)
 	judgementRepo := repository.NewJudgementRepository(db)
+	judgeQueueRepo := repository.NewJudgeQueueRepository(db)
+	jurySolutionRepo := repository.NewJurySolutionRepository(db)
 
 	// Initialize queries
 	problemListQuery := query.NewProblemListQuery(db)
@@ -63,6 +94,9 @@ func main() {
 		problemListQuery,  // 查询题目列表
 		problemRepo,       // 题目信息仓库
 		problemFilesystem, // 题目数据管理
+		jurySolutionRepo,  // 验题程序仓库（公开题目前检查）
+		sandboxPool,       // 校验测试数据的沙箱
+		languages,         // 编程语言配置
 	)
 
 	// 评测管理
@@ -71,24 +105,51 @@ func main() {
 		judgementRepo,  // 题目结果仓库（管理试题通过情况）
 		problemRepo,    // 题目信息仓库（管理题目基本信息）
 		userRepo,       // 用户仓库（管理提交者）
+		judgeQueueRepo, // 评测队列仓库
 		problemFilesystem,
 		submissionWs,
 		contestService,
+		sandboxPool,    // 评测沙箱
+		languages,      // 编程语言配置
+		cfg.Judge.CompileCacheSize, // 编译结果缓存
+		cfg.Judge.CompileCacheTTL,
 		5, // 评测机 worker 个数
+		cfg.Queue.Instance,
+		cfg.Queue.Lease,
+		cfg.Queue.PollInterval,
+	)
+
+	// 验题
+	jurySolutionService := service.NewJurySolutionService(jurySolutionRepo, problemRepo, judgeService)
+
+	// 自测
+	customTestService := service.NewCustomTestService(
+		problemRepo,
+		problemFilesystem,
+		sandboxPool,
+		languages,
+		customTestWs,
+		cfg.Judge.CustomTestInterval, // 同一用户两次自测的最短间隔
 	)
 
 	// Initialize controllers
-	configController := controller.NewConfigController()
+	configController := controller.NewConfigController(languages)
 	problemController := controller.NewProblemController(problemService, judgeService)
 	submissionController := controller.NewSubmissionController(judgeService, userService, submissionWs)
 	authController := controller.NewAuthController(authService)
 	userController := controller.NewUserController(userService, judgeService, contestService)
 	contestController := controller.NewContestController(contestService, problemService, userService, judgeService)
 	imageController := controller.NewImageController(imageService)
+	judgeController := controller.NewJudgeController(judgeService, cfg.Server.MetricsToken)
+	customTestController := controller.NewCustomTestController(customTestService, problemService, customTestWs)
+	jurySolutionController := controller.NewJurySolutionController(jurySolutionService)
 
 	// Initialize router
 	router := gin.Default()
 
+	// Prometheus metrics
+	router.GET("/metrics", judgeController.Metrics)
+
 	// Public routes
 	public := router.Group("/api")
 	publicOptional := public.Group("")
@@ -106,7 +167,8 @@ func main() {
 		publicOptional.POST("/contest/list", contestController.ListContests)
 
 		public.GET("/ws/submission/:id", submissionController.HandleSubmissionWS)
-		public.POST("/submission", submissionController.GetSubmissionDetail)
+		public.GET("/ws/custom-test/:id", customTestController.HandleCustomTestWS)
+		publicOptional.POST("/submission", submissionController.GetSubmissionDetail)
 		public.POST("/submission/list", submissionController.ListSubmissions)
 
 		public.POST("/user", userController.GetUser)
@@ -133,6 +195,7 @@ func main() {
 
 		protected.POST("/problem/submit", problemController.SubmitCode)
 		protected.POST("/problem/mine", problemController.MineProblems)
+		protected.POST("/problem/custom-test", customTestController.RunCustomTest)
 
 		protected.POST("/upload/avatar", imageController.UploadAvatar)
 
@@ -141,9 +204,19 @@ func main() {
 		{
 			juryRoutes.POST("/problem/edit", problemController.CreateOrUpdateProblem)
 			juryRoutes.POST("/problem/delete", problemController.DeleteProblem)
+			juryRoutes.POST("/problem/rejudge", problemController.RejudgeProblem)
+			juryRoutes.POST("/problem/import", problemController.ImportProblem)
+			juryRoutes.POST("/problem/export", problemController.ExportProblem)
+			juryRoutes.POST("/problem/solution/edit", jurySolutionController.CreateOrUpdateSolution)
+			juryRoutes.POST("/problem/solution/delete", jurySolutionController.DeleteSolution)
+			juryRoutes.POST("/problem/solution/list", jurySolutionController.ListSolutions)
+			juryRoutes.POST("/problem/solution/verify", jurySolutionController.VerifySolutions)
 
 			juryRoutes.POST("/contest/edit", contestController.CreateOrUpdateContest)
 			juryRoutes.POST("/contest/delete", contestController.DeleteContest)
+			juryRoutes.POST("/contest/rejudge", contestController.RejudgeContest)
+
+			juryRoutes.POST("/submission/rejudge", submissionController.RejudgeSubmission)
 
 			juryRoutes.POST("/upload/banner", imageController.UploadBanner)
 
@@ -151,6 +224,16 @@ func main() {
 			juryRoutes.POST("/testdata/download", problemController.DownloadTestData)
 			juryRoutes.POST("/testdata/delete", problemController.DeleteTestData)
 			juryRoutes.POST("/testdata/config/upload", problemController.UploadConfig)
+			juryRoutes.POST("/testdata/validator/upload", problemController.UploadValidator)
+			juryRoutes.POST("/testdata/validate", problemController.ValidateTestData)
+			juryRoutes.POST("/testdata/validation", problemController.GetValidationReport)
+			juryRoutes.POST("/testdata/std/upload", problemController.UploadStdSolution)
+			juryRoutes.POST("/testdata/std", problemController.GetStdSolution)
+			juryRoutes.POST("/testdata/generate", problemController.GenerateAnswers)
+			juryRoutes.POST("/testdata/generator/upload", problemController.UploadGenerator)
+			juryRoutes.POST("/testdata/generator/config/upload", problemController.UploadGeneratorConfig)
+			juryRoutes.POST("/testdata/generator/config", problemController.GetGeneratorConfig)
+			juryRoutes.POST("/testdata/generator/run", problemController.GenerateTests)
 		}
 
 		adminRoutes := protected.Group("")
@@ -160,6 +243,7 @@ func main() {
 			adminRoutes.POST("/problem/all", problemController.AllProblems)
 			adminRoutes.POST("/contest/all", contestController.AllContests)
 			adminRoutes.POST("/submission/all", submissionController.AllSubmissions)
+			adminRoutes.POST("/judge/status", judgeController.GetStatus)
 		}
 
 		superRoutes := protected.Group("")
@@ -170,5 +254,33 @@ func main() {
 	}
 
 	// Start server
-	router.Run(":" + cfg.Server.Port)
+	server := &http.Server{
+		Addr:    ":" + cfg.Server.Port,
+		Handler: router,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// 收到退出信号后依次停止接收请求、等待评测完成、停止定时任务并断开 websocket
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-signalCtx.Done()
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down HTTP server: %v", err)
+	}
+	if err := judgeService.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Judge service stopped with unfinished tasks: %v", err)
+	}
+	contestService.StopRankingUpdater()
+	submissionWs.Close()
+	customTestWs.Close()
+	log.Println("Server stopped")
 }
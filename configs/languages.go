@@ -0,0 +1,8 @@
+package configs
+
+import _ "embed"
+
+// 内置的编程语言配置，未找到配置文件时使用
+//
+//go:embed languages.yaml
+var DefaultLanguages []byte
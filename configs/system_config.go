@@ -13,23 +13,19 @@ var SystemConfig = model.SyncConfigResponse{
 		"zh-CN": {ID: "zh-CN", Description: "简体中文"},
 		"zh-TW": {ID: "zh-TW", Description: "繁体中文"},
 	},
-	CodeLangs: map[string]model.CodeLang{
-		"pas":    {ID: "pas", Ext: []string{".pas"}, Description: "Free Pascal 3.2.2", Ratio: 1},
-		"c":      {ID: "c", Ext: []string{".c"}, Description: "C11 9.3.1", Ratio: 1},
-		"cpp98":  {ID: "cpp98", Ext: []string{}, Description: "C++98 9.3.1", Ratio: 1},
-		"cpp11":  {ID: "cpp11", Ext: []string{}, Description: "C++11 9.3.1", Ratio: 1},
-		"cpp14":  {ID: "cpp14", Ext: []string{".cpp", ".cc", ".cxx"}, Description: "C++14 9.3.1", Ratio: 1},
-		"python": {ID: "python", Ext: []string{".py"}, Description: "Python 3.8", Ratio: 2},
-	},
+	// CodeLangs 由编程语言配置文件（configs/languages.yaml）提供
 	Verdicts: map[string]model.Verdict{
 		"AC":  {ID: "AC", Description: "Accepted", Abbr: "AC", Color: "#67C23A"},
 		"WA":  {ID: "WA", Description: "Wrong Answer", Abbr: "WA", Color: "#F56C6C"},
+		"PE":  {ID: "PE", Description: "Presentation Error", Abbr: "PE", Color: "#F56C6C"},
+		"PC":  {ID: "PC", Description: "Partially Correct", Abbr: "PC", Color: "#95C23A"},
 		"RE":  {ID: "RE", Description: "Runtime Error", Abbr: "RE", Color: "#6A3BC0"},
 		"TLE": {ID: "TLE", Description: "Time Limit Exceeded", Abbr: "TLE", Color: "#E6A23C"},
 		"MLE": {ID: "MLE", Description: "Memory Limit Exceeded", Abbr: "MLE", Color: "#E6A23C"},
 		"OLE": {ID: "OLE", Description: "Output Limit Exceeded", Abbr: "OLE", Color: "#E6A23C"},
 		"CE":  {ID: "CE", Description: "Compile Error", Abbr: "CE", Color: "#FFC116"},
 		"UKE": {ID: "UKE", Description: "Unknown Error", Abbr: "UKE", Color: "#909399"},
+		"SKP": {ID: "SKP", Description: "Skipped", Abbr: "Skipped", Color: "#C0C4CC"},
 		"PD":  {ID: "PD", Description: "Pending", Abbr: "Pending", Color: "#89D1F5"},
 		"JD":  {ID: "JD", Description: "Judging", Abbr: "Judging", Color: "#3498DB"},
 	},
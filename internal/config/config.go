@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,10 +14,15 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
+	Sandbox  SandboxConfig
+	Queue    QueueConfig
+	Judge    JudgeConfig
 }
 
 type ServerConfig struct {
-	Port string
+	Port            string
+	MetricsToken    string        // 访问 /metrics 所需的令牌，为空时不校验
+	ShutdownTimeout time.Duration // 停止服务时等待请求与评测完成的最长时间
 }
 
 type DatabaseConfig struct {
@@ -25,6 +33,33 @@ type JWTConfig struct {
 	Secret string
 }
 
+// 评测沙箱（go-judge）配置。测试数据等文件以主机路径传给沙箱，
+// 题目目录（/var/problemset）需在各沙箱主机上以相同路径挂载，健康检查会读取其中的探测文件确认
+type SandboxConfig struct {
+	Endpoints      []SandboxEndpoint
+	HealthInterval time.Duration // 健康检查间隔
+}
+
+// 评测配置
+type JudgeConfig struct {
+	LanguageFile       string        // 编程语言配置文件（YAML 或 JSON）
+	CustomTestInterval time.Duration // 同一用户两次自测的最短间隔
+	CompileCacheSize   int           // 缓存的编译结果数，为 0 时不缓存
+	CompileCacheTTL    time.Duration // 编译结果的有效期
+}
+
+// 评测队列配置
+type QueueConfig struct {
+	Instance     string        // 评测实例名，用于标记队列租约的持有者
+	Lease        time.Duration // 租约时长，实例失联超过该时长后其任务可被重新认领
+	PollInterval time.Duration // 轮询队列的间隔
+}
+
+type SandboxEndpoint struct {
+	URL         string // go-judge 地址，题目目录需在各主机上以相同路径挂载
+	Concurrency int    // 同时运行的请求数上限
+}
+
 func Load() *Config {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
@@ -33,7 +68,9 @@ func Load() *Config {
 
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8283"),
+			Port:            getEnv("SERVER_PORT", "8283"),
+			MetricsToken:    getEnv("METRICS_TOKEN", ""),
+			ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
 		},
 		Database: DatabaseConfig{
 			DSN: getEnv("DATABASE_DSN", "root:zjh13159530487@tcp(localhost:3306)/oj_system?charset=utf8mb4&parseTime=True&loc=Local"),
@@ -41,6 +78,21 @@ func Load() *Config {
 		JWT: JWTConfig{
 			Secret: getEnv("JWT_SECRET", "your-secret-key"),
 		},
+		Sandbox: SandboxConfig{
+			Endpoints:      getSandboxEndpoints(),
+			HealthInterval: getEnvDuration("GO_JUDGE_HEALTH_INTERVAL", 10*time.Second),
+		},
+		Judge: JudgeConfig{
+			LanguageFile:       getEnv("LANGUAGE_CONFIG", "configs/languages.yaml"),
+			CustomTestInterval: getEnvDuration("CUSTOM_TEST_INTERVAL", 5*time.Second),
+			CompileCacheSize:   getEnvInt("COMPILE_CACHE_SIZE", 256),
+			CompileCacheTTL:    getEnvDuration("COMPILE_CACHE_TTL", 30*time.Minute),
+		},
+		Queue: QueueConfig{
+			Instance:     getEnv("JUDGE_INSTANCE", hostname()),
+			Lease:        getEnvDuration("JUDGE_QUEUE_LEASE", time.Minute),
+			PollInterval: getEnvDuration("JUDGE_QUEUE_POLL_INTERVAL", 2*time.Second),
+		},
 	}
 }
 
@@ -49,4 +101,76 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "localhost"
+	}
+	return name
+}
+
+// 读取以逗号分隔的列表
+func getEnvList(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer %s=%q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration %s=%q, using %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
+// 读取沙箱地址列表，每项形如 "http://host:5050" 或 "http://host:5050|8"，后者指定该后端的并发数
+func getSandboxEndpoints() []SandboxEndpoint {
+	concurrency, err := strconv.Atoi(getEnv("GO_JUDGE_CONCURRENCY", "4"))
+	if err != nil || concurrency <= 0 {
+		concurrency = 4
+	}
+
+	var endpoints []SandboxEndpoint
+	for _, item := range getEnvList("GO_JUDGE_ENDPOINTS", []string{"http://localhost:5050"}) {
+		endpoint := SandboxEndpoint{URL: item, Concurrency: concurrency}
+		if url, limit, ok := strings.Cut(item, "|"); ok {
+			endpoint.URL = url
+			if n, err := strconv.Atoi(limit); err == nil && n > 0 {
+				endpoint.Concurrency = n
+			} else {
+				log.Printf("Invalid concurrency in GO_JUDGE_ENDPOINTS item %q", item)
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reisen-be/configs"
+	"reisen-be/internal/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 编程语言注册表，评测与 /sync-config 共用
+type LanguageRegistry struct {
+	languages map[model.CodeLangId]*model.LanguageConfig
+}
+
+// 从 YAML 或 JSON 文件加载编程语言配置，文件不存在时使用内置配置
+func LoadLanguages(path string) (*LanguageRegistry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("Language config %s not found, using built-in languages", path)
+		data, path = configs.DefaultLanguages, "built-in languages"
+	} else if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Languages []model.LanguageConfig `yaml:"languages"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	registry := &LanguageRegistry{languages: make(map[model.CodeLangId]*model.LanguageConfig)}
+	for i := range file.Languages {
+		lang := &file.Languages[i]
+		switch {
+		case lang.ID == "":
+			return nil, fmt.Errorf("language #%d has no id", i+1)
+		case lang.SourceFile == "" || lang.OutputFile == "":
+			return nil, fmt.Errorf("language %s has no source or output file", lang.ID)
+		case len(lang.CompileArgs) == 0 || len(lang.RunArgs) == 0:
+			return nil, fmt.Errorf("language %s has no compile or run command", lang.ID)
+		}
+		if _, ok := registry.languages[model.CodeLangId(lang.ID)]; ok {
+			return nil, fmt.Errorf("duplicate language %s", lang.ID)
+		}
+		if lang.Ratio <= 0 {
+			lang.Ratio = 1
+		}
+		if lang.MemoryRatio <= 0 {
+			lang.MemoryRatio = 1
+		}
+		if lang.Ext == nil {
+			lang.Ext = []string{}
+		}
+		registry.languages[model.CodeLangId(lang.ID)] = lang
+	}
+	return registry, nil
+}
+
+// 获取编程语言配置，不存在时返回 nil
+func (r *LanguageRegistry) Get(id model.CodeLangId) *model.LanguageConfig {
+	return r.languages[id]
+}
+
+// 供前端展示的编程语言列表
+func (r *LanguageRegistry) CodeLangs() map[string]model.CodeLang {
+	langs := make(map[string]model.CodeLang, len(r.languages))
+	for id, lang := range r.languages {
+		langs[string(id)] = lang.CodeLang
+	}
+	return langs
+}
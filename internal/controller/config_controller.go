@@ -2,20 +2,24 @@ package controller
 
 import (
     "reisen-be/configs"
+    "reisen-be/internal/config"
 
     "github.com/gin-gonic/gin"
 )
 
-type ConfigController struct{}
+type ConfigController struct {
+    languages *config.LanguageRegistry
+}
 
-func NewConfigController() *ConfigController {
-    return &ConfigController{}
+func NewConfigController(languages *config.LanguageRegistry) *ConfigController {
+    return &ConfigController{languages: languages}
 }
 
 // 同步配置文件
 func (c *ConfigController) SyncConfig(ctx *gin.Context) {
     // 直接从配置中获取数据
     response := configs.SystemConfig
+    response.CodeLangs = c.languages.CodeLangs()
     
     // 如果需要从数据库获取，可以在这里添加数据库查询逻辑
     // 例如：
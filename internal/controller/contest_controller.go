@@ -107,6 +107,22 @@ func (c *ContestController) DeleteContest(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "contest deleted successfully"})
 }
 
+// 重测比赛的评测记录
+func (c *ContestController) RejudgeContest(ctx *gin.Context) {
+	var req model.ContestRejudgeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := c.judgeService.Rejudge(&model.RejudgeFilter{Contest: &req.Contest, Verdict: req.Verdict})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.RejudgeResponse{Count: count})
+}
+
 // 提交主题库代码评测
 func (c *ContestController) SubmitCode(ctx *gin.Context) {
 	var req model.JudgeRequest
@@ -141,6 +157,10 @@ func (c *ContestController) SubmitCode(ctx *gin.Context) {
 	}
 
 	submission, err := c.judgeService.SubmitCode(&req, user.ID)
+	if errors.Is(err, service.ErrJudgeShuttingDown) {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"reisen-be/internal/model"
+	"reisen-be/internal/service"
+	"reisen-be/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CustomTestController struct {
+	customTestService *service.CustomTestService
+	problemService    *service.ProblemService
+	customTestWs      *websocket.CustomTestWs
+}
+
+func NewCustomTestController(
+	customTestService *service.CustomTestService,
+	problemService *service.ProblemService,
+	customTestWs *websocket.CustomTestWs,
+) *CustomTestController {
+	return &CustomTestController{
+		customTestService: customTestService,
+		problemService:    problemService,
+		customTestWs:      customTestWs,
+	}
+}
+
+// 以自定义输入运行代码
+func (c *CustomTestController) RunCustomTest(ctx *gin.Context) {
+	var req model.CustomTestRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取题目信息
+	problem, err := c.problemService.GetProblem(req.Problem)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从上下文中获取用户
+	user := ctx.MustGet("user").(*model.User)
+
+	// 普通用户只能自测公开试题
+	if user.Role == model.RoleUser {
+		if problem.Status != model.ProblemStatusPublic {
+			ctx.Status(http.StatusForbidden)
+			return
+		}
+	}
+
+	id, err := c.customTestService.Start(&req, user.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrCustomTestInputTooLong) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrCustomTestTooFrequent) {
+			ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.CustomTestResponse{
+		ID: id,
+	})
+}
+
+// 处理自测结果跟踪
+func (c *CustomTestController) HandleCustomTestWS(ctx *gin.Context) {
+	c.customTestWs.HandleConnection(ctx.Writer, ctx.Request, ctx.Param("id"))
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"reisen-be/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JudgeController struct {
+	judgeService *service.JudgeService
+	metricsToken string
+}
+
+// metricsToken 非空时，访问 /metrics 需携带 "Authorization: Bearer <metricsToken>"
+func NewJudgeController(judgeService *service.JudgeService, metricsToken string) *JudgeController {
+	return &JudgeController{
+		judgeService: judgeService,
+		metricsToken: metricsToken,
+	}
+}
+
+// 获取评测机与评测队列状态
+func (c *JudgeController) GetStatus(ctx *gin.Context) {
+	status, err := c.judgeService.Status()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
+
+// 以 Prometheus 文本格式输出评测指标
+func (c *JudgeController) Metrics(ctx *gin.Context) {
+	if c.metricsToken != "" {
+		expected := "Bearer " + c.metricsToken
+		if subtle.ConstantTimeCompare([]byte(ctx.GetHeader("Authorization")), []byte(expected)) != 1 {
+			ctx.Status(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	ctx.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := c.judgeService.WriteMetrics(ctx.Writer); err != nil {
+		ctx.Status(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"reisen-be/internal/model"
+	"reisen-be/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JurySolutionController struct {
+	jurySolutionService *service.JurySolutionService
+}
+
+func NewJurySolutionController(jurySolutionService *service.JurySolutionService) *JurySolutionController {
+	return &JurySolutionController{
+		jurySolutionService: jurySolutionService,
+	}
+}
+
+// 创建或更新验题程序
+func (c *JurySolutionController) CreateOrUpdateSolution(ctx *gin.Context) {
+	var req model.JurySolutionEditRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.jurySolutionService.Save(&req.Solution); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.JurySolutionEditResponse{Solution: req.Solution})
+}
+
+// 删除验题程序
+func (c *JurySolutionController) DeleteSolution(ctx *gin.Context) {
+	var req model.JurySolutionDeleteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.jurySolutionService.Delete(req.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// 获取题目的验题程序及验证结果
+func (c *JurySolutionController) ListSolutions(ctx *gin.Context) {
+	var req model.JurySolutionListRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	solutions, err := c.jurySolutionService.List(req.ProblemID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.JurySolutionListResponse{Solutions: solutions})
+}
+
+// 用当前测试数据验证题目的全部验题程序
+func (c *JurySolutionController) VerifySolutions(ctx *gin.Context) {
+	var req model.JurySolutionVerifyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	count, err := c.jurySolutionService.Verify(req.ProblemID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrJuryVerificationRunning):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrJudgeShuttingDown):
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, model.JurySolutionVerifyResponse{Count: count})
+}
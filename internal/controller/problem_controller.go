@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"os"
 	"reisen-be/internal/model"
@@ -46,6 +47,10 @@ func (c *ProblemController) CreateOrUpdateProblem(ctx *gin.Context) {
 		err = c.problemService.UpdateProblem(&req.Problem)
 	}
 
+	if errors.Is(err, service.ErrJurySolutionsUnverified) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -197,6 +202,14 @@ func (c *ProblemController) SubmitCode(ctx *gin.Context) {
 	}
 
 	submission, err := c.judgeService.SubmitCode(&req, user.ID)
+	if errors.Is(err, service.ErrJudgeShuttingDown) {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrProblemNotInContest) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -233,6 +246,22 @@ func (c *ProblemController) DeleteProblem(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "problem deleted successfully"})
 }
 
+// 重测题目的评测记录
+func (c *ProblemController) RejudgeProblem(ctx *gin.Context) {
+	var req model.ProblemRejudgeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := c.judgeService.Rejudge(&model.RejudgeFilter{Problem: &req.Problem, Verdict: req.Verdict})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.RejudgeResponse{Count: count})
+}
+
 // 上传测试数据
 func (c *ProblemController) UploadTestData(ctx *gin.Context) {
 	file, err := ctx.FormFile("file")
@@ -256,14 +285,90 @@ func (c *ProblemController) UploadTestData(ctx *gin.Context) {
 	defer os.Remove(uploadPath)
 
 	// 处理测试数据
-	if err := c.problemService.UploadTestdata(req.ProblemID, uploadPath); err != nil {
+	report, err := c.problemService.UploadTestdata(req.ProblemID, uploadPath)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	response := model.TestdataUploadResponse{Valid: true, Validation: report}
+	if report != nil && !report.Valid {
+		response.Valid = false
+		response.Message = "testdata failed validation"
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// 上传校验器源文件，可附带 testlib.h
+func (c *ProblemController) UploadValidator(ctx *gin.Context) {
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req model.ValidatorUploadRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 保存上传文件
+	prefix := os.TempDir() + "/validator_" + strconv.FormatUint(uint64(req.ProblemID), 10) + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	sourcePath := prefix + ".cpp"
+	if err := ctx.SaveUploadedFile(file, sourcePath); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.Remove(sourcePath)
+
+	testlibPath := ""
+	if testlib, err := ctx.FormFile("testlib"); err == nil {
+		testlibPath = prefix + "_testlib.h"
+		if err := ctx.SaveUploadedFile(testlib, testlibPath); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer os.Remove(testlibPath)
+	}
+
+	if err := c.problemService.UploadValidator(req.ProblemID, sourcePath, testlibPath); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// 用校验器重新检查测试数据
+func (c *ProblemController) ValidateTestData(ctx *gin.Context) {
+	var req model.TestdataValidateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	report, err := c.problemService.ValidateTestdata(req.ProblemID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.TestdataValidateResponse{Report: report})
+}
+
+// 获取最近一次的测试数据校验报告
+func (c *ProblemController) GetValidationReport(ctx *gin.Context) {
+	var req model.TestdataValidateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	report, err := c.problemService.GetValidationReport(req.ProblemID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.TestdataValidateResponse{Report: report})
+}
+
 // 下载测试数据
 func (c *ProblemController) DownloadTestData(ctx *gin.Context) {
 	var req model.TestdataDownloadRequest
@@ -323,3 +428,183 @@ func (c *ProblemController) GetConfig(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusOK, gin.H{"config": config})
 }
+
+// 上传标准程序
+func (c *ProblemController) UploadStdSolution(ctx *gin.Context) {
+	var req model.StdSolutionUploadRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	solution := &model.StdSolution{Lang: req.Lang, Code: req.Code}
+	if err := c.problemService.UploadStdSolution(req.ProblemID, solution); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// 获取标准程序
+func (c *ProblemController) GetStdSolution(ctx *gin.Context) {
+	var req model.StdSolutionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	solution, err := c.problemService.GetStdSolution(req.ProblemID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"solution": solution})
+}
+
+// 运行标准程序生成答案文件
+func (c *ProblemController) GenerateAnswers(ctx *gin.Context) {
+	var req model.AnswerGenerateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	report, err := c.problemService.GenerateAnswers(req.ProblemID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// 上传生成器源文件，可同时上传多个文件（包括 testlib.h）
+func (c *ProblemController) UploadGenerator(ctx *gin.Context) {
+	var req model.GeneratorUploadRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	files := form.File["file"]
+	if len(files) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
+		return
+	}
+
+	for i, file := range files {
+		// 保存上传文件
+		uploadPath := os.TempDir() + "/generator_" + strconv.FormatUint(uint64(req.ProblemID), 10) + "_" + strconv.FormatInt(time.Now().UnixNano(), 10) + "_" + strconv.Itoa(i)
+		if err := ctx.SaveUploadedFile(file, uploadPath); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		err := c.problemService.UploadGeneratorFile(req.ProblemID, file.Filename, uploadPath)
+		os.Remove(uploadPath)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// 上传测试数据生成配置（生成器与生成脚本）
+func (c *ProblemController) UploadGeneratorConfig(ctx *gin.Context) {
+	var req model.GeneratorConfigUploadRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := c.problemService.UploadGeneratorConfig(req.ProblemID, &req.Config); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// 获取测试数据生成配置
+func (c *ProblemController) GetGeneratorConfig(ctx *gin.Context) {
+	var req model.GeneratorConfigRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	config, err := c.problemService.GetGeneratorConfig(req.ProblemID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"config": config})
+}
+
+// 执行生成脚本生成测试数据
+func (c *ProblemController) GenerateTests(ctx *gin.Context) {
+	var req model.TestGenerateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	report, err := c.problemService.GenerateTests(req.ProblemID, req.Force)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// 导入题目包，创建新的私有题目
+func (c *ProblemController) ImportProblem(ctx *gin.Context) {
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req model.ProblemImportRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Format != "" && req.Format != "polygon" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "unsupported package format " + req.Format})
+		return
+	}
+
+	user := ctx.MustGet("user").(*model.User)
+
+	// 保存上传文件
+	uploadPath := os.TempDir() + "/import_" + strconv.FormatUint(uint64(user.ID), 10) + "_" + strconv.FormatInt(time.Now().UnixNano(), 10) + ".zip"
+	if err := ctx.SaveUploadedFile(file, uploadPath); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.Remove(uploadPath)
+
+	report, err := c.problemService.ImportPolygon(uploadPath, user.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// 导出题目包
+func (c *ProblemController) ExportProblem(ctx *gin.Context) {
+	var req model.ProblemExportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	zipPath, err := c.problemService.ExportPolygon(req.Problem)
+	if err != nil {
+		if errors.Is(err, service.ErrPolygonCheckerUnsupported) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// 设置响应头让浏览器下载文件
+	ctx.FileAttachment(*zipPath, "problem_"+strconv.FormatUint(uint64(req.Problem), 10)+"_polygon.zip")
+}
@@ -42,6 +42,14 @@ func (c *SubmissionController) GetSubmissionDetail(ctx *gin.Context) {
 		return
 	}
 
+	// 交互过程记录仅对出题人可见
+	user := ctx.MustGet("user").(*model.User)
+	if user == nil || user.Role < model.RoleJury {
+		for i := range submission.Testcases {
+			submission.Testcases[i].Transcript = nil
+		}
+	}
+
 	ctx.JSON(http.StatusOK, model.SubmissionDetailResponse{
 		Submission: *submission,
 	})
@@ -109,4 +117,20 @@ func (c *SubmissionController) ListSubmissions(ctx *gin.Context) {
 // 获取评测记录列表
 func (c *SubmissionController) AllSubmissions(ctx *gin.Context) {
 	c.ListSubmissions(ctx)
-}
\ No newline at end of file
+}
+
+// 重测评测记录
+func (c *SubmissionController) RejudgeSubmission(ctx *gin.Context) {
+	var req model.SubmissionRejudgeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := c.judgeService.Rejudge(&model.RejudgeFilter{Submission: &req.Submission})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.RejudgeResponse{Count: count})
+}
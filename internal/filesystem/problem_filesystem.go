@@ -1,12 +1,18 @@
 package filesystem
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reisen-be/internal/model"
 	"reisen-be/internal/utils"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,6 +33,11 @@ func (f *ProblemFilesystem) GetProblemPath(problemID model.ProblemId) string {
 	return filepath.Join(f.dataDir, fmt.Sprint(problemID))
 }
 
+// 沙箱挂载探测文件，用于检查各沙箱主机能否以相同路径读取题目目录
+func (f *ProblemFilesystem) GetSandboxProbePath() string {
+	return filepath.Join(f.dataDir, ".sandbox-probe")
+}
+
 func (f *ProblemFilesystem) GetDataPath(problemID model.ProblemId) string {
 	return filepath.Join(f.GetProblemPath(problemID), "tests")
 }
@@ -51,12 +62,138 @@ func (f *ProblemFilesystem) GetJudgeConfig(problemID model.ProblemId) (*model.Ju
 	return &config, nil
 }
 
+// 写入评测配置文件
+func (f *ProblemFilesystem) SaveJudgeConfig(problemID model.ProblemId, config *model.JudgeConfig) error {
+	problemPath := f.GetProblemPath(problemID)
+	if err := os.MkdirAll(problemPath, 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.GetConfigPath(problemID), data, 0644)
+}
+
 // 从数据文件直接生成配置文件
 func (f *ProblemFilesystem) GenerateConfig(problem model.ProblemCore) error {
+	pairs, err := f.listDataPairs(problem.ID)
+	if err != nil {
+		return err
+	}
+
+	var testcases []model.TestCaseConfig
+	for i, pair := range pairs {
+		testcases = append(testcases, model.TestCaseConfig{
+			ID:         i + 1,
+			InputFile:  pair[0],
+			OutputFile: pair[1],
+			Score:      10,
+		})
+	}
+
+	config := model.JudgeConfig{
+		TimeLimit:   problem.LimitTime,
+		MemoryLimit: problem.LimitMemory,
+		TestCases:   testcases,
+		CheckerType: "strict",
+	}
+	return f.SaveJudgeConfig(problem.ID, &config)
+}
+
+// 按数据目录中的文件更新配置文件的测试点列表，保留其余配置。
+// 已有测试点沿用其编号与分值，新的测试点编号依次递增并归入新增的子任务；子任务中移除已不存在的测试点
+func (f *ProblemFilesystem) MergeConfig(problem model.ProblemCore) error {
+	// 尚无配置文件时直接生成
+	if _, err := os.Stat(f.GetConfigPath(problem.ID)); os.IsNotExist(err) {
+		return f.GenerateConfig(problem)
+	}
+	config, err := f.GetJudgeConfig(problem.ID)
+	if err != nil {
+		return err
+	}
+	pairs, err := f.listDataPairs(problem.ID)
+	if err != nil {
+		return err
+	}
+
+	previous := map[string]model.TestCaseConfig{}
+	nextID := 1
+	for _, tc := range config.TestCases {
+		previous[tc.InputFile] = tc
+		nextID = max(nextID, tc.ID+1)
+	}
+	kept := map[int]bool{}
+	testcases := []model.TestCaseConfig{}
+	var added []model.TestCaseConfig
+	for _, pair := range pairs {
+		tc, ok := previous[pair[0]]
+		if !ok {
+			tc = model.TestCaseConfig{ID: nextID, InputFile: pair[0], Score: 10}
+			nextID++
+			added = append(added, tc)
+		}
+		tc.OutputFile = pair[1]
+		kept[tc.ID] = true
+		testcases = append(testcases, tc)
+	}
+
+	subtasks := []model.SubtaskConfig{}
+	for _, subtask := range config.Subtasks {
+		var ids []int
+		for _, id := range subtask.TestCases {
+			if kept[id] {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > 0 {
+			subtask.TestCases = ids
+			subtasks = append(subtasks, subtask)
+		}
+	}
+	remaining := map[int]bool{}
+	for _, subtask := range subtasks {
+		remaining[subtask.ID] = true
+	}
+	for i := range subtasks {
+		var deps []int
+		for _, id := range subtasks[i].Dependencies {
+			if remaining[id] {
+				deps = append(deps, id)
+			}
+		}
+		subtasks[i].Dependencies = deps
+	}
+
+	// 不属于任何子任务的测试点不会被评测，新的测试点归入末尾新增的子任务
+	if len(config.Subtasks) > 0 && len(added) > 0 {
+		subtask := model.SubtaskConfig{Policy: model.SubtaskPolicySum}
+		for _, previous := range config.Subtasks {
+			subtask.ID = max(subtask.ID, previous.ID)
+		}
+		subtask.ID++
+		for _, tc := range added {
+			subtask.TestCases = append(subtask.TestCases, tc.ID)
+			subtask.Score += tc.Score
+		}
+		subtasks = append(subtasks, subtask)
+	}
+
+	config.TimeLimit = problem.LimitTime
+	config.MemoryLimit = problem.LimitMemory
+	config.TestCases = testcases
+	if len(config.Subtasks) > 0 {
+		config.Subtasks = subtasks
+	}
+	return f.SaveJudgeConfig(problem.ID, config)
+}
+
+// 数据目录中成对的输入与答案文件（相对题目目录），按文件名排序，数字文件名按数值排序
+func (f *ProblemFilesystem) listDataPairs(problemID model.ProblemId) ([][2]string, error) {
 	// 确保数据目录存在
-	dataPath := f.GetDataPath(problem.ID)
+	dataPath := f.GetDataPath(problemID)
 	if _, err := os.Stat(dataPath); os.IsNotExist(err) {
-		return errors.New("暂无数据")
+		return nil, errors.New("暂无数据")
 	}
 
 	entries, _ := os.ReadDir(dataPath)
@@ -74,38 +211,28 @@ func (f *ProblemFilesystem) GenerateConfig(problem model.ProblemCore) error {
 		}
 	}
 
-	var count = 0
-
-	var testcases []model.TestCaseConfig
-	for base, iFile := range IMap {
-		if oFile, ok := OMap[base]; ok {
-			count = count + 1
-			testcases = append(testcases, model.TestCaseConfig{
-				ID:         count,
-				InputFile:  "tests/" + iFile,
-				OutputFile: "tests/" + oFile,
-				Score:      10,
-			})
+	var bases []string
+	for base := range IMap {
+		if _, ok := OMap[base]; ok {
+			bases = append(bases, base)
 		}
 	}
+	sort.Slice(bases, func(i, j int) bool {
+		a, errA := strconv.Atoi(bases[i])
+		b, errB := strconv.Atoi(bases[j])
+		if errA == nil && errB == nil && a != b {
+			return a < b
+		}
+		return bases[i] < bases[j]
+	})
 
-	config := model.JudgeConfig{
-		TimeLimit:   problem.LimitTime,
-		MemoryLimit: problem.LimitMemory,
-		TestCases:   testcases,
-		CheckerType: "strict",
-	}
-
-	data, err := yaml.Marshal(config)
-	if err != nil {
-		return err
+	pairs := make([][2]string, len(bases))
+	for i, base := range bases {
+		pairs[i] = [2]string{"tests/" + IMap[base], "tests/" + OMap[base]}
 	}
-
-	configPath := f.GetConfigPath(problem.ID)
-	return os.WriteFile(configPath, data, 0644)
+	return pairs, nil
 }
 
-
 func (f *ProblemFilesystem) UploadTestdata(problemID model.ProblemId, filePath string) error {
 	// 确保问题目录存在
 	problemPath := f.GetProblemPath(problemID)
@@ -194,3 +321,296 @@ func (f *ProblemFilesystem) GetConfig(problemID model.ProblemId) (*model.Testdat
 	}
 	return &config, nil
 }
+
+// 校验器源文件相对题目目录的路径
+const ValidatorSource = "validator/validator.cpp"
+
+func (f *ProblemFilesystem) GetValidatorPath(problemID model.ProblemId) string {
+	return filepath.Join(f.GetProblemPath(problemID), ValidatorSource)
+}
+
+func (f *ProblemFilesystem) GetValidationReportPath(problemID model.ProblemId) string {
+	return filepath.Join(f.GetProblemPath(problemID), "validation.json")
+}
+
+func (f *ProblemFilesystem) HasValidator(problemID model.ProblemId) bool {
+	_, err := os.Stat(f.GetValidatorPath(problemID))
+	return err == nil
+}
+
+// 保存校验器源文件，testlibPath 非空时一并保存 testlib.h
+func (f *ProblemFilesystem) UploadValidator(problemID model.ProblemId, sourcePath, testlibPath string) error {
+	validatorPath := f.GetValidatorPath(problemID)
+	validatorDir := filepath.Dir(validatorPath)
+	if err := os.MkdirAll(validatorDir, 0755); err != nil {
+		return err
+	}
+	if err := copyFile(sourcePath, validatorPath); err != nil {
+		return err
+	}
+	if testlibPath != "" {
+		return copyFile(testlibPath, filepath.Join(validatorDir, "testlib.h"))
+	}
+	return nil
+}
+
+// 列出数据目录下的全部输入文件（*.in），按文件名排序
+func (f *ProblemFilesystem) ListInputFiles(problemID model.ProblemId) ([]string, error) {
+	dataPath := f.GetDataPath(problemID)
+	entries, err := os.ReadDir(dataPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("暂无数据")
+		}
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".in") {
+			files = append(files, entry.Name())
+		}
+	}
+	return files, nil
+}
+
+func (f *ProblemFilesystem) SaveValidationReport(problemID model.ProblemId, report *model.ValidationReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.GetValidationReportPath(problemID), data, 0644)
+}
+
+// 获取最近一次的校验报告，尚未校验时返回 nil
+func (f *ProblemFilesystem) GetValidationReport(problemID model.ProblemId) (*model.ValidationReport, error) {
+	data, err := os.ReadFile(f.GetValidationReportPath(problemID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var report model.ValidationReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (f *ProblemFilesystem) DeleteValidationReport(problemID model.ProblemId) error {
+	if err := os.Remove(f.GetValidationReportPath(problemID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+func (f *ProblemFilesystem) GetStdSolutionPath(problemID model.ProblemId) string {
+	return filepath.Join(f.GetProblemPath(problemID), "std.json")
+}
+
+func (f *ProblemFilesystem) SaveStdSolution(problemID model.ProblemId, solution *model.StdSolution) error {
+	problemPath := f.GetProblemPath(problemID)
+	if err := os.MkdirAll(problemPath, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(solution)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.GetStdSolutionPath(problemID), data, 0644)
+}
+
+// 获取标准程序，尚未上传时返回 nil
+func (f *ProblemFilesystem) GetStdSolution(problemID model.ProblemId) (*model.StdSolution, error) {
+	data, err := os.ReadFile(f.GetStdSolutionPath(problemID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var solution model.StdSolution
+	if err := json.Unmarshal(data, &solution); err != nil {
+		return nil, err
+	}
+	return &solution, nil
+}
+
+// 将答案写入数据目录下与输入文件同名的 .out 文件，并移除同名的 .ans 文件
+func (f *ProblemFilesystem) WriteAnswer(problemID model.ProblemId, inputFile string, answer io.Reader) error {
+	dataPath := f.GetDataPath(problemID)
+	base := strings.TrimSuffix(inputFile, filepath.Ext(inputFile))
+
+	if err := f.WriteDataFile(problemID, base+".out", answer); err != nil {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join(dataPath, base+".ans")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// 评测所用全部内容的摘要：题目类型与时空限制、配置文件、测试数据以及判分器、交互器源文件。
+// 其中任一变化都会改变摘要，验题结果据此判断是否过时
+func (f *ProblemFilesystem) GetTestdataFingerprint(problem model.ProblemCore) (string, error) {
+	config, err := f.GetJudgeConfig(problem.ID)
+	if err != nil {
+		return "", err
+	}
+
+	files := []string{"config.yml"}
+	for _, tc := range config.TestCases {
+		files = append(files, tc.InputFile, tc.OutputFile)
+	}
+	for _, program := range []*model.ProgramConfig{config.Checker, config.Interactor} {
+		if program != nil {
+			files = append(files, program.Source, filepath.Join(filepath.Dir(program.Source), "testlib.h"))
+		}
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%d\x00", problem.Type, problem.LimitTime, problem.LimitMemory)
+	root := f.GetProblemPath(problem.ID)
+	for _, name := range files {
+		file, err := os.Open(filepath.Join(root, name))
+		if os.IsNotExist(err) {
+			fmt.Fprintf(h, "%s\x00-\x00", name)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		info, err := file.Stat()
+		if err == nil {
+			fmt.Fprintf(h, "%s\x00%d\x00", name, info.Size())
+			_, err = io.Copy(h, file)
+		}
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 写入数据目录下的文件，name 不能包含路径
+func (f *ProblemFilesystem) WriteDataFile(problemID model.ProblemId, name string, content io.Reader) error {
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	dataPath := f.GetDataPath(problemID)
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Join(dataPath, name))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// 删除数据目录下的文件，文件不存在时忽略
+func (f *ProblemFilesystem) DeleteDataFile(problemID model.ProblemId, name string) error {
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	if err := os.Remove(filepath.Join(f.GetDataPath(problemID), name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *ProblemFilesystem) DataFileExists(problemID model.ProblemId, name string) bool {
+	_, err := os.Stat(filepath.Join(f.GetDataPath(problemID), name))
+	return err == nil
+}
+
+func (f *ProblemFilesystem) GetGeneratorConfigPath(problemID model.ProblemId) string {
+	return filepath.Join(f.GetProblemPath(problemID), "generator.yml")
+}
+
+// 已生成文件的缓存记录：文件名 → 生成器与参数的摘要
+func (f *ProblemFilesystem) GetGeneratedManifestPath(problemID model.ProblemId) string {
+	return filepath.Join(f.GetProblemPath(problemID), "generated.json")
+}
+
+// 获取测试数据生成配置，尚未配置时返回 nil
+func (f *ProblemFilesystem) GetGeneratorConfig(problemID model.ProblemId) (*model.GeneratorConfig, error) {
+	data, err := os.ReadFile(f.GetGeneratorConfigPath(problemID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var config model.GeneratorConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse generator config: %v", err)
+	}
+	return &config, nil
+}
+
+func (f *ProblemFilesystem) SaveGeneratorConfig(problemID model.ProblemId, config *model.GeneratorConfig) error {
+	problemPath := f.GetProblemPath(problemID)
+	if err := os.MkdirAll(problemPath, 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.GetGeneratorConfigPath(problemID), data, 0644)
+}
+
+// 保存生成器源文件（或 testlib.h 等头文件）到题目目录下的 generators 目录
+func (f *ProblemFilesystem) UploadGeneratorFile(problemID model.ProblemId, name, srcPath string) error {
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	generatorDir := filepath.Join(f.GetProblemPath(problemID), "generators")
+	if err := os.MkdirAll(generatorDir, 0755); err != nil {
+		return err
+	}
+	return copyFile(srcPath, filepath.Join(generatorDir, name))
+}
+
+func (f *ProblemFilesystem) GetGeneratedManifest(problemID model.ProblemId) (map[string]string, error) {
+	manifest := map[string]string{}
+	data, err := os.ReadFile(f.GetGeneratedManifestPath(problemID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (f *ProblemFilesystem) SaveGeneratedManifest(problemID model.ProblemId, manifest map[string]string) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.GetGeneratedManifestPath(problemID), data, 0644)
+}
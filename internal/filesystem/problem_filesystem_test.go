@@ -0,0 +1,141 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"reisen-be/internal/model"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMergeConfig(t *testing.T) {
+	problem := model.ProblemCore{ID: 1, LimitTime: 2000, LimitMemory: 512}
+	tests := []struct {
+		name     string
+		existing *model.JudgeConfig
+		files    []string
+		want     model.JudgeConfig
+	}{
+		{
+			name:  "no existing config",
+			files: []string{"10.in", "10.out", "2.in", "2.ans", "1.in", "1.out", "3.in"},
+			want: model.JudgeConfig{
+				TimeLimit: 2000, MemoryLimit: 512, CheckerType: "strict",
+				TestCases: []model.TestCaseConfig{
+					{ID: 1, InputFile: "tests/1.in", OutputFile: "tests/1.out", Score: 10},
+					{ID: 2, InputFile: "tests/2.in", OutputFile: "tests/2.ans", Score: 10},
+					{ID: 3, InputFile: "tests/10.in", OutputFile: "tests/10.out", Score: 10},
+				},
+			},
+		},
+		{
+			name: "existing settings kept",
+			existing: &model.JudgeConfig{
+				TimeLimit: 1000, MemoryLimit: 256, CheckerType: "float", Epsilon: 1e-6,
+				InputFileName: "a.in",
+				TestCases: []model.TestCaseConfig{
+					{ID: 5, InputFile: "tests/b.in", OutputFile: "tests/b.ans", Score: 30},
+					{ID: 7, InputFile: "tests/a.in", OutputFile: "tests/a.out", Score: 70},
+				},
+			},
+			files: []string{"a.in", "a.out", "b.in", "b.out", "c.in", "c.out"},
+			want: model.JudgeConfig{
+				TimeLimit: 2000, MemoryLimit: 512, CheckerType: "float", Epsilon: 1e-6,
+				InputFileName: "a.in",
+				TestCases: []model.TestCaseConfig{
+					{ID: 7, InputFile: "tests/a.in", OutputFile: "tests/a.out", Score: 70},
+					{ID: 5, InputFile: "tests/b.in", OutputFile: "tests/b.out", Score: 30},
+					{ID: 8, InputFile: "tests/c.in", OutputFile: "tests/c.out", Score: 10},
+				},
+			},
+		},
+		{
+			name: "removed testcases dropped from subtasks",
+			existing: &model.JudgeConfig{
+				CheckerType: "token",
+				TestCases: []model.TestCaseConfig{
+					{ID: 1, InputFile: "tests/1.in", OutputFile: "tests/1.out", Score: 10},
+					{ID: 2, InputFile: "tests/2.in", OutputFile: "tests/2.out", Score: 10},
+					{ID: 3, InputFile: "tests/3.in", OutputFile: "tests/3.out", Score: 10},
+				},
+				Subtasks: []model.SubtaskConfig{
+					{ID: 1, Score: 40, TestCases: []int{1}},
+					{ID: 2, Score: 60, TestCases: []int{2, 3}, Dependencies: []int{1}},
+				},
+			},
+			files: []string{"2.in", "2.out", "3.in", "3.out"},
+			want: model.JudgeConfig{
+				TimeLimit: 2000, MemoryLimit: 512, CheckerType: "token",
+				TestCases: []model.TestCaseConfig{
+					{ID: 2, InputFile: "tests/2.in", OutputFile: "tests/2.out", Score: 10},
+					{ID: 3, InputFile: "tests/3.in", OutputFile: "tests/3.out", Score: 10},
+				},
+				Subtasks: []model.SubtaskConfig{
+					{ID: 2, Score: 60, TestCases: []int{2, 3}},
+				},
+			},
+		},
+		{
+			name: "added testcases put into a new subtask",
+			existing: &model.JudgeConfig{
+				CheckerType: "strict",
+				TestCases: []model.TestCaseConfig{
+					{ID: 1, InputFile: "tests/1.in", OutputFile: "tests/1.out", Score: 40},
+					{ID: 2, InputFile: "tests/2.in", OutputFile: "tests/2.out", Score: 60},
+				},
+				Subtasks: []model.SubtaskConfig{
+					{ID: 1, Score: 40, TestCases: []int{1}},
+					{ID: 3, Score: 60, Policy: model.SubtaskPolicyAll, TestCases: []int{2}, Dependencies: []int{1}},
+				},
+			},
+			files: []string{"1.in", "1.out", "2.in", "2.out", "3.in", "3.out", "4.in", "4.ans"},
+			want: model.JudgeConfig{
+				TimeLimit: 2000, MemoryLimit: 512, CheckerType: "strict",
+				TestCases: []model.TestCaseConfig{
+					{ID: 1, InputFile: "tests/1.in", OutputFile: "tests/1.out", Score: 40},
+					{ID: 2, InputFile: "tests/2.in", OutputFile: "tests/2.out", Score: 60},
+					{ID: 3, InputFile: "tests/3.in", OutputFile: "tests/3.out", Score: 10},
+					{ID: 4, InputFile: "tests/4.in", OutputFile: "tests/4.ans", Score: 10},
+				},
+				Subtasks: []model.SubtaskConfig{
+					{ID: 1, Score: 40, TestCases: []int{1}},
+					{ID: 3, Score: 60, Policy: model.SubtaskPolicyAll, TestCases: []int{2}, Dependencies: []int{1}},
+					{ID: 4, Score: 20, Policy: model.SubtaskPolicySum, TestCases: []int{3, 4}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewProblemFilesystem(t.TempDir())
+			if err := os.MkdirAll(f.GetDataPath(problem.ID), 0755); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(f.GetDataPath(problem.ID), name), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.existing != nil {
+				if err := f.SaveJudgeConfig(problem.ID, tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := f.MergeConfig(problem); err != nil {
+				t.Fatal(err)
+			}
+			got, err := f.GetJudgeConfig(problem.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 按写入的文件内容比较，不区分空切片与 nil
+			gotData, _ := yaml.Marshal(got)
+			wantData, _ := yaml.Marshal(&tt.want)
+			if string(gotData) != string(wantData) {
+				t.Fatalf("got\n%s\nwant\n%s", gotData, wantData)
+			}
+		})
+	}
+}
@@ -22,6 +22,20 @@ type CodeLang struct {
 	Ratio       float64  `json:"ratio"`
 }
 
+// 编程语言的编译与运行配置
+type LanguageConfig struct {
+	CodeLang       `yaml:",inline"`
+	TimeExtra      int      `yaml:"time_extra"`      // 额外的时间限制(ms)，在按 Ratio 放大后加上
+	MemoryRatio    float64  `yaml:"memory_ratio"`    // 内存限制倍率
+	MemoryOverhead int      `yaml:"memory_overhead"` // 运行时额外占用的内存(MB)，如虚拟机
+	SourceFile     string   `yaml:"source_file"`     // 源文件名
+	OutputFile     string   `yaml:"output_file"`     // 编译产物文件名
+	CompileArgs    []string `yaml:"compile_args"`
+	CompileEnv     []string `yaml:"compile_env"`
+	RunArgs        []string `yaml:"run_args"`
+	RunEnv         []string `yaml:"run_env"`
+}
+
 // 判题结果
 type Verdict struct {
 	ID          string `json:"id"`
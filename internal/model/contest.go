@@ -143,9 +143,10 @@ type OIDetail struct {
 	Problems   map[ProblemId]OIProblem `json:"problems"`
 }
 
-// IOI problem cell data (same as OI for now)
+// IOI problem cell data
 type IOIProblem struct {
-	Score int `json:"score"`
+	Score    int         `json:"score"`
+	Subtasks map[int]int `json:"subtasks,omitempty"` // 各子任务历次提交的最高得分
 }
 
 // IOI ranking detail
@@ -243,6 +244,12 @@ type ContestDeleteRequest struct {
 type ContestDeleteResponse struct {
 }
 
+// 比赛重测请求
+type ContestRejudgeRequest struct {
+	Contest ContestId  `json:"contest"`
+	Verdict *VerdictId `json:"verdict,omitempty"`
+}
+
 // 比赛报名请求
 type ContestSignupRequest struct {
 	Contest ContestId `json:"contest"`
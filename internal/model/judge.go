@@ -18,6 +18,9 @@ const (
 	VerdictOLE VerdictId = "OLE"
 	VerdictCE  VerdictId = "CE"
 	VerdictUKE VerdictId = "UKE"
+	VerdictPE  VerdictId = "PE"
+	VerdictPC  VerdictId = "PC"
+	VerdictSKP VerdictId = "SKP"
 )
 
 
@@ -82,6 +85,8 @@ type Testcase struct {
 	Input   *string   `json:"input,omitempty"`   // 输入内容摘要
 	Output  *string   `json:"output,omitempty"`  // 输出内容摘要
 	Checker *string   `json:"checker,omitempty"` // 校验器输出信息（包括 UKE 错误信息）
+
+	Transcript *string `json:"transcript,omitempty"` // 交互过程记录（仅出题人可见）
 }
 
 type TestcaseList []Testcase
@@ -98,6 +103,29 @@ func (t TestcaseList) Value() (driver.Value, error) {
 	return json.Marshal(t)
 }
 
+// 子任务详情
+type SubtaskResult struct {
+	ID        int       `json:"id"`
+	Verdict   VerdictId `json:"verdict"`
+	Score     int       `json:"score"`     // 子任务得分
+	FullScore int       `json:"fullScore"` // 子任务满分
+	Testcases []int     `json:"testcases"` // 包含的测试点编号
+}
+
+type SubtaskList []SubtaskResult
+
+func (t *SubtaskList) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, t)
+}
+
+func (t SubtaskList) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
 // 编译信息
 type CompileInfo struct {
 	Success bool   `json:"success"`
@@ -116,6 +144,29 @@ func (t CompileInfo) Value() (driver.Value, error) {
 	return json.Marshal(t)
 }
 
+// 评测使用的时空限制
+type JudgeLimits struct {
+	TimeLimit         int `json:"timeLimit"`         // 题目时间限制(ms)
+	MemoryLimit       int `json:"memoryLimit"`       // 题目内存限制(MB)
+	ActualTimeLimit   int `json:"actualTimeLimit"`   // 按编程语言调整后的时间限制(ms)
+	ActualMemoryLimit int `json:"actualMemoryLimit"` // 按编程语言调整后的内存限制(MB)
+	ClockLimit        int `json:"clockLimit"`        // 墙钟时间限制(ms)
+	StackLimit        int `json:"stackLimit"`        // 栈空间限制(MB)
+	ProcessLimit      int `json:"processLimit"`      // 进程（线程）数限制
+}
+
+func (t *JudgeLimits) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, t)
+}
+
+func (t JudgeLimits) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
 // 提交记录核心信息
 type SubmissionCore struct {
 	BaseModel
@@ -139,6 +190,8 @@ type Submission struct {
 	Code        string       `gorm:"type:text" json:"code"`
 	CompileInfo *CompileInfo `gorm:"type:json" json:"compile,omitempty"`
 	Testcases   TestcaseList `gorm:"type:json" json:"detail"`
+	Subtasks    SubtaskList  `gorm:"type:json" json:"subtasks,omitempty"`
+	Limits      *JudgeLimits `gorm:"type:json" json:"limits,omitempty"`
 }
 
 // 轻量提交记录（用于记录列表）
@@ -168,6 +221,32 @@ type JudgeResponse struct {
 	Submission SubmissionId `json:"submission"`
 }
 
+// 自测请求，以自定义输入运行代码，不产生评测记录
+type CustomTestRequest struct {
+	Problem ProblemId  `json:"problem"`
+	Lang    CodeLangId `json:"lang"`
+	Code    string     `json:"code"`
+	Input   string     `json:"input"`
+}
+
+// 自测响应
+type CustomTestResponse struct {
+	ID string `json:"id"` // 自测编号，用于订阅运行结果
+}
+
+// 自测结果
+type CustomTestResult struct {
+	ID          string       `json:"id"`
+	Verdict     VerdictId    `json:"verdict"` // 运行正常结束时为 AC
+	CompileInfo *CompileInfo `json:"compileInfo,omitempty"`
+	Limits      *JudgeLimits `json:"limits,omitempty"`
+	Time        *int         `json:"time,omitempty"`
+	Memory      *int         `json:"memory,omitempty"`
+	Output      *string      `json:"output,omitempty"`  // 程序输出，超出长度时截断
+	Stderr      *string      `json:"stderr,omitempty"`  // 标准错误输出
+	Message     *string      `json:"message,omitempty"` // 运行错误信息
+}
+
 // 记录过滤参数
 type SubmissionFilter struct {
 	User    *UserId     `json:"user,omitempty"`
@@ -206,6 +285,24 @@ type SubmissionDetailResponse struct {
 	Submission SubmissionFull `json:"submission"`
 }
 
+// 重测范围，Verdict 非空时只重测该结果的记录
+type RejudgeFilter struct {
+	Submission *SubmissionId
+	Problem    *ProblemId
+	Contest    *ContestId
+	Verdict    *VerdictId
+}
+
+// 记录重测请求
+type SubmissionRejudgeRequest struct {
+	Submission SubmissionId `json:"submission"`
+}
+
+// 重测响应
+type RejudgeResponse struct {
+	Count int `json:"count"` // 重新加入评测队列的记录数
+}
+
 // 题目结果（用于个人练习数据展示和题目列表）
 type Judgement struct {
 	ProblemID  ProblemId  `gorm:"primaryKey" json:"problem"`
@@ -250,10 +347,54 @@ type JudgementUpdateResponse struct {
 
 // 评测配置
 type JudgeConfig struct {
-	TimeLimit      int              `json:"timeLimit"`
-	MemoryLimit    int              `json:"memoryLimit"`
-	TestCases      []TestCaseConfig `json:"testCases"`
-	CheckerType    string           `json:"checkerType"`    // "strict", "custom", "float"
+	TimeLimit       int              `json:"timeLimit"`
+	MemoryLimit     int              `json:"memoryLimit"`
+	TestCases       []TestCaseConfig `json:"testCases"`
+	CheckerType     string           `json:"checkerType"`               // "strict", "loose", "token", "icase", "float", "unordered", "custom"
+	Epsilon         float64          `json:"epsilon,omitempty"`         // float 判分器允许的绝对误差
+	RelativeEpsilon float64          `json:"relativeEpsilon,omitempty"` // float 判分器允许的相对误差
+	Checker         *ProgramConfig   `json:"checker,omitempty"`         // custom 判分器程序（testlib 格式）
+	Type            ProblemType      `json:"type,omitempty"`            // 题目类型，以题目信息为准
+	Interactor      *ProgramConfig   `json:"interactor,omitempty"`      // 交互题的交互器（testlib 格式）
+	Transcript      bool             `json:"transcript,omitempty"`      // 是否记录交互过程
+	Subtasks        []SubtaskConfig  `json:"subtasks,omitempty"`        // 子任务，为空时各测试点独立计分
+	SkipOnFail      bool             `json:"skipOnFail,omitempty"`      // 子任务已无法得分时跳过其余测试点
+	Execution       string           `json:"execution,omitempty"`       // 测试点执行方式，默认 "all"，ACM 赛制比赛中默认 "ordered"
+
+	InputFileName  string `json:"inputFileName,omitempty"`  // 文件输入时的输入文件名，为空时从标准输入读取
+	OutputFileName string `json:"outputFileName,omitempty"` // 文件输出时的输出文件名，为空时输出到标准输出
+
+	ClockLimit   int `json:"clockLimit,omitempty"`   // 墙钟时间限制(ms)，默认为调整后时间限制的 2 倍加 1 秒
+	StackLimit   int `json:"stackLimit,omitempty"`   // 栈空间限制(MB)，默认与调整后内存限制相同
+	OutputLimit  int `json:"outputLimit,omitempty"`  // 输出限制(KB)，默认为答案文件大小的 2 倍加 1MB
+	ProcessLimit int `json:"processLimit,omitempty"` // 进程（线程）数限制，默认 50
+}
+
+const (
+	ExecutionAll     = "all"     // 并行评测全部测试点
+	ExecutionOrdered = "ordered" // 按顺序评测，跳过首个未通过测试点之后的测试点
+	ExecutionCancel  = "cancel"  // 并行评测，出现未通过的测试点后取消其余测试点
+)
+
+const (
+	SubtaskPolicySum = "sum" // 按测试点得分比例计分
+	SubtaskPolicyMin = "min" // 按得分比例最低的测试点计分
+	SubtaskPolicyAll = "all" // 全部通过才得分
+)
+
+// 子任务配置
+type SubtaskConfig struct {
+	ID           int    `json:"id"`
+	Score        int    `json:"score"`                  // 子任务分值
+	Policy       string `json:"policy"`                 // 计分方式，默认 "sum"
+	TestCases    []int  `json:"testCases"`              // 包含的测试点编号
+	Dependencies []int  `json:"dependencies,omitempty"` // 依赖的子任务，依赖未满分时本子任务不评测
+}
+
+// 题目附带的程序（判分器等）
+type ProgramConfig struct {
+	Source string     `json:"source"`         // 源文件（相对题目目录）
+	Lang   CodeLangId `json:"lang,omitempty"` // 编译语言，默认 cpp14
 }
 
 // 测试用例配置
@@ -268,4 +409,44 @@ type TestCaseConfig struct {
 type JudgeTask struct {
 	Submission
 	Config JudgeConfig // 评测配置
+	Silent bool        // 不推送评测进度（验题等不产生提交记录的评测）
+}
+
+// 评测实例的运行状态
+type JudgeStatus struct {
+	Instance  string               `json:"instance"`
+	Workers   int                  `json:"workers"`
+	Busy      int                  `json:"busy"`     // 正在评测的 worker 数
+	InFlight  int                  `json:"inFlight"` // 已认领但尚未产出结果的任务数
+	Queue     JudgeQueueStatus     `json:"queue"`
+	Sandboxes []SandboxStatus      `json:"sandboxes"`
+	Verdicts  map[VerdictId]uint64 `json:"verdicts"` // 本实例启动以来各结果的评测任务数
+}
+
+// 评测队列（所有实例共享）的状态
+type JudgeQueueStatus struct {
+	Total   int64 `json:"total"`   // 队列中的提交数
+	Waiting int64 `json:"waiting"` // 等待认领的提交数
+	Owned   int64 `json:"owned"`   // 本实例持有租约的提交数
+}
+
+// 沙箱后端的运行状态
+type SandboxStatus struct {
+	Name     string `json:"name"`
+	Healthy  bool   `json:"healthy"`
+	Load     int    `json:"load"`     // 正在使用该后端的评测任务数
+	Running  int    `json:"running"`  // 正在运行的请求数
+	Waiting  int    `json:"waiting"`  // 等待运行名额的请求数
+	Requests uint64 `json:"requests"` // 累计运行请求数
+	Errors   uint64 `json:"errors"`   // 累计请求失败数
+}
+
+// 评测队列项，评测机通过租约认领，租约过期后可被重新认领
+type JudgeQueueItem struct {
+	SubmissionID SubmissionId `gorm:"primaryKey;autoIncrement:false"`
+	LeaseOwner   string       `gorm:"size:100;index"` // 持有租约的评测实例
+	LeaseUntil   *time.Time   `gorm:"index"`          // 租约到期时间，为空表示未被认领
+	Attempts     int          // 被认领的次数
+	Rejudge      bool         // 是否为重测，重测完成后需重新计算通过情况与榜单
+	CreatedAt    time.Time
 }
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type JurySolutionId uint
+
+type JurySolutionStatus string
+
+const (
+	JurySolutionStatusPending JurySolutionStatus = "pending" // 等待验证
+	JurySolutionStatusJudging JurySolutionStatus = "judging" // 正在评测
+	JurySolutionStatusDone    JurySolutionStatus = "done"    // 已得到验证结果
+)
+
+// 可接受的评测结果，满足其中之一即符合预期
+type VerdictList []VerdictId
+
+func (v VerdictList) Contains(verdict VerdictId) bool {
+	for _, expected := range v {
+		if expected == verdict {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *VerdictList) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+func (v VerdictList) Value() (driver.Value, error) {
+	return json.Marshal(v)
+}
+
+// 各子任务（按子任务编号）可接受的评测结果
+type SubtaskExpectations map[int]VerdictList
+
+func (e *SubtaskExpectations) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, e)
+}
+
+func (e SubtaskExpectations) Value() (driver.Value, error) {
+	return json.Marshal(e)
+}
+
+type StringList []string
+
+func (s *StringList) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s StringList) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// 出题人上传的验题程序及其预期结果，评测结果与用户提交记录分开保存
+type JurySolution struct {
+	BaseModel
+	ID               JurySolutionId      `json:"id"`
+	ProblemID        ProblemId           `json:"problem" gorm:"index"`
+	Name             string              `json:"name"`
+	Lang             CodeLangId          `json:"lang"`
+	Code             string              `json:"code" gorm:"type:text"`
+	Expected         VerdictList         `json:"expected" gorm:"type:json"`                   // 可接受的总体结果
+	ExpectedSubtasks SubtaskExpectations `json:"expectedSubtasks,omitempty" gorm:"type:json"` // 可接受的子任务结果
+
+	// 最近一次验证的结果
+	Status      JurySolutionStatus `json:"status"`
+	Verdict     *VerdictId         `json:"verdict,omitempty"`
+	Score       *int               `json:"score,omitempty"`
+	TimeUsed    *int               `json:"time,omitempty"`
+	MemoryUsed  *int               `json:"memory,omitempty"`
+	CompileInfo *CompileInfo       `json:"compile,omitempty" gorm:"type:json"`
+	Testcases   TestcaseList       `json:"detail,omitempty" gorm:"type:json"`
+	Subtasks    SubtaskList        `json:"subtasks,omitempty" gorm:"type:json"`
+	Matched     *bool              `json:"matched,omitempty"`                     // 结果是否符合预期
+	Mismatches  StringList         `json:"mismatches,omitempty" gorm:"type:json"` // 不符合预期之处
+	JudgedAt    *time.Time         `json:"judgedAt,omitempty"`
+	Fingerprint string             `json:"fingerprint,omitempty" gorm:"size:64"` // 验证时测试数据与评测配置的摘要
+}
+
+type JurySolutionEditRequest struct {
+	Solution JurySolution `json:"solution"`
+}
+
+type JurySolutionEditResponse struct {
+	Solution JurySolution `json:"solution"`
+}
+
+type JurySolutionDeleteRequest struct {
+	ID JurySolutionId `json:"id"`
+}
+
+type JurySolutionListRequest struct {
+	ProblemID ProblemId `json:"problem"`
+}
+
+type JurySolutionListResponse struct {
+	Solutions []JurySolution `json:"solutions"`
+}
+
+type JurySolutionVerifyRequest struct {
+	ProblemID ProblemId `json:"problem"`
+}
+
+type JurySolutionVerifyResponse struct {
+	Count int `json:"count"` // 开始验证的程序数
+}
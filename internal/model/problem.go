@@ -19,16 +19,18 @@ const (
 )
 
 type Statement struct {
-	Background string `json:"background,omitempty"`
-	Legend     string `json:"legend,omitempty"`
-	FormatI    string `json:"formatI,omitempty"`
-	FormatO    string `json:"formatO,omitempty"`
-	Examples   []struct {
-		DataI string `json:"dataI"`
-		DataO string `json:"dataO"`
-	} `json:"examples"`
-	Hint string `json:"hint,omitempty"`
-	Note string `json:"note,omitempty"`
+	Background string    `json:"background,omitempty"`
+	Legend     string    `json:"legend,omitempty"`
+	FormatI    string    `json:"formatI,omitempty"`
+	FormatO    string    `json:"formatO,omitempty"`
+	Examples   []Example `json:"examples"`
+	Hint       string    `json:"hint,omitempty"`
+	Note       string    `json:"note,omitempty"`
+}
+
+type Example struct {
+	DataI string `json:"dataI"`
+	DataO string `json:"dataO"`
 }
 
 type StatementsMap map[string]Statement
@@ -95,6 +97,8 @@ type Problem struct {
 	// 测试数据相关字段
 	HasTestdata bool `json:"hasTestdata" gorm:"default:false"`
 	HasConfig   bool `json:"hasConfig"   gorm:"default:false"`
+	// 最近一次校验发现不合法的输入文件
+	InvalidTestdata bool `json:"invalidTestdata" gorm:"default:false"`
 }
 
 // 实现自定义 JSON 序列化/反序列化
@@ -137,6 +141,12 @@ type ProblemDeleteRequest struct {
 type ProblemDeleteResponse struct {
 }
 
+// 题目重测请求
+type ProblemRejudgeRequest struct {
+	Problem ProblemId  `json:"problem"`
+	Verdict *VerdictId `json:"verdict,omitempty"`
+}
+
 // 题目信息请求（携带用户信息用于查询用户本题提交信息）
 type ProblemRequest struct {
 	Problem ProblemId `json:"problem"`
@@ -173,3 +183,22 @@ type ProblemAllResponse struct {
 	Total    int64         `json:"total"`
 	Problems []ProblemCore `json:"problems"`
 }
+
+// 题目包导入请求（multipart，文件字段为 file）
+type ProblemImportRequest struct {
+	Format string `form:"format"` // 题目包格式，目前只支持 "polygon"
+}
+
+// 题目包导入结果
+type ProblemImportReport struct {
+	Problem    ProblemId             `json:"problem"`
+	Warnings   []string              `json:"warnings"`             // 未能导入或按近似方式导入的内容
+	Generated  *TestGenerateReport   `json:"generated,omitempty"`  // 包中缺少生成的测试点时的生成报告
+	Answers    *AnswerGenerateReport `json:"answers,omitempty"`    // 包中缺少答案文件时的答案生成报告
+	Validation *ValidationReport     `json:"validation,omitempty"` // 包含校验器时的校验报告
+}
+
+// 题目包导出请求
+type ProblemExportRequest struct {
+	Problem ProblemId `json:"problem"`
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // 测试数据配置文件结构
 type TestdataConfig struct {
 	TimeLimit   int      `yaml:"time_limit"`    // 时间限制(ms)
@@ -22,6 +24,7 @@ type TestdataUploadResponse struct {
 	Valid       bool 	         `json:"valid"`
 	Message     string         `json:"message"`
 	Config      TestdataConfig `json:"config"`
+	Validation  *ValidationReport `json:"validation,omitempty"` // 配置了校验器时的校验报告
 }
 
 type TestdataDownloadRequest struct {
@@ -57,3 +60,114 @@ type TestdataConfigRequest struct {
 type TestdataConfigResponse struct {
 	Config TestdataConfig `json:"config"`
 }
+
+// 单个输入文件的校验结果
+type ValidationResult struct {
+	File    string `json:"file"`    // 输入文件名
+	Valid   bool   `json:"valid"`   // 是否通过校验器
+	Message string `json:"message"` // 校验器输出的信息
+}
+
+// 测试数据校验报告
+type ValidationReport struct {
+	ValidatedAt time.Time          `json:"validatedAt"`
+	Valid       bool               `json:"valid"`   // 全部输入文件均通过校验
+	Message     string             `json:"message"` // 校验器编译失败等整体信息
+	Results     []ValidationResult `json:"results"`
+}
+
+type ValidatorUploadRequest struct {
+	ProblemID ProblemId `form:"problem"`
+}
+
+type TestdataValidateRequest struct {
+	ProblemID ProblemId `json:"problem"`
+}
+
+type TestdataValidateResponse struct {
+	Report *ValidationReport `json:"report"`
+}
+
+// 题目的标准程序，用于生成答案文件
+type StdSolution struct {
+	Lang CodeLangId `json:"lang"`
+	Code string     `json:"code"`
+}
+
+type StdSolutionUploadRequest struct {
+	ProblemID ProblemId  `json:"problem"`
+	Lang      CodeLangId `json:"lang"`
+	Code      string     `json:"code"`
+}
+
+type StdSolutionRequest struct {
+	ProblemID ProblemId `json:"problem"`
+}
+
+type AnswerGenerateRequest struct {
+	ProblemID ProblemId `json:"problem"`
+}
+
+// 标准程序在单个输入文件上的运行结果
+type AnswerGenerateResult struct {
+	File    string    `json:"file"`    // 输入文件名
+	Verdict VerdictId `json:"verdict"` // 只有全部为 AC 时才写入答案文件
+	Time    int       `json:"time"`    // 用时(ms)
+	Memory  int       `json:"memory"`  // 内存(KB)
+	Message string    `json:"message,omitempty"`
+}
+
+// 生成答案文件的报告，运行时间可用于确定时间限制
+type AnswerGenerateReport struct {
+	Success     bool                   `json:"success"` // 答案文件已写入并更新配置文件
+	Lang        CodeLangId             `json:"lang"`
+	CompileInfo *CompileInfo           `json:"compileInfo,omitempty"`
+	Limits      *JudgeLimits           `json:"limits,omitempty"` // 运行时使用的时空限制
+	MaxTime     int                    `json:"maxTime"`          // 最长用时(ms)
+	MaxMemory   int                    `json:"maxMemory"`        // 最大内存(KB)
+	Results     []AnswerGenerateResult `json:"results"`
+}
+
+// 测试数据生成配置，生成器源文件位于题目目录下
+type GeneratorConfig struct {
+	Generators map[string]ProgramConfig `json:"generators"` // 生成器名 → 生成器程序
+	Script     string                   `json:"script"`     // 生成脚本，每行形如 "gen 1 100 > 5.in"，# 开头为注释
+}
+
+type GeneratorUploadRequest struct {
+	ProblemID ProblemId `form:"problem"`
+}
+
+type GeneratorConfigUploadRequest struct {
+	ProblemID ProblemId       `json:"problem"`
+	Config    GeneratorConfig `json:"config"`
+}
+
+type GeneratorConfigRequest struct {
+	ProblemID ProblemId `json:"problem"`
+}
+
+type TestGenerateRequest struct {
+	ProblemID ProblemId `json:"problem"`
+	Force     bool      `json:"force"` // 忽略缓存，重新生成全部文件
+}
+
+// 生成脚本中单条命令的执行结果
+type TestGenerateResult struct {
+	Line    int    `json:"line"`    // 脚本行号
+	Command string `json:"command"` // 脚本中的命令
+	File    string `json:"file"`    // 生成的文件名
+	Cached  bool   `json:"cached"`  // 生成器与参数均未变化，沿用已有文件
+	Time    int    `json:"time"`    // 用时(ms)
+	Error   string `json:"error,omitempty"`
+}
+
+type TestGenerateReport struct {
+	Success        bool                  `json:"success"`   // 全部命令执行成功
+	Generated      int                   `json:"generated"` // 新生成的文件数
+	Cached         int                   `json:"cached"`    // 沿用的文件数
+	Results        []TestGenerateResult  `json:"results"`
+	Answers        *AnswerGenerateReport `json:"answers,omitempty"`        // 有标准程序时重新生成答案的报告
+	MissingAnswers []string              `json:"missingAnswers,omitempty"` // 仍缺少答案文件的输入文件
+	Validation     *ValidationReport     `json:"validation,omitempty"`     // 配置了校验器时的校验报告
+}
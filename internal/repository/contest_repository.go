@@ -15,6 +15,11 @@ func NewContestRepository(db *gorm.DB) *ContestRepository {
 	return &ContestRepository{db: db}
 }
 
+// 返回在事务 tx 中执行的仓库
+func (r *ContestRepository) WithTx(tx *gorm.DB) *ContestRepository {
+	return &ContestRepository{db: tx}
+}
+
 func (r *ContestRepository) Create(contest *model.Contest) error {
 	return r.db.Create(contest).Error
 }
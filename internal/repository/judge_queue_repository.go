@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"reisen-be/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type JudgeQueueRepository struct {
+	db *gorm.DB
+}
+
+func NewJudgeQueueRepository(db *gorm.DB) *JudgeQueueRepository {
+	return &JudgeQueueRepository{db: db}
+}
+
+// 返回在事务 tx 中执行的仓库
+func (r *JudgeQueueRepository) WithTx(tx *gorm.DB) *JudgeQueueRepository {
+	return &JudgeQueueRepository{db: tx}
+}
+
+// 将提交加入评测队列，已在队列中时清除其租约并重新计算认领次数
+func (r *JudgeQueueRepository) Enqueue(submissionID model.SubmissionId, rejudge bool) error {
+	return r.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]any{"lease_owner": "", "lease_until": nil, "attempts": 0, "rejudge": rejudge}),
+	}).Create(&model.JudgeQueueItem{SubmissionID: submissionID, Rejudge: rejudge}).Error
+}
+
+func (r *JudgeQueueRepository) GetByID(submissionID model.SubmissionId) (*model.JudgeQueueItem, error) {
+	var item model.JudgeQueueItem
+	if err := r.db.First(&item, submissionID).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// 认领至多 limit 个未被认领或租约已过期的提交，按入队顺序
+func (r *JudgeQueueRepository) Claim(owner string, lease time.Duration, limit int) ([]model.JudgeQueueItem, error) {
+	var items []model.JudgeQueueItem
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("lease_until IS NULL OR lease_until < ?", now).
+			Order("created_at ASC, submission_id ASC").
+			Limit(limit).
+			Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		ids := make([]model.SubmissionId, len(items))
+		for i := range items {
+			ids[i] = items[i].SubmissionID
+		}
+		until := now.Add(lease)
+		return tx.Model(&model.JudgeQueueItem{}).
+			Where("submission_id IN ?", ids).
+			Updates(map[string]any{
+				"lease_owner": owner,
+				"lease_until": until,
+				"attempts":    gorm.Expr("attempts + 1"),
+			}).Error
+	})
+	return items, err
+}
+
+// 延长 owner 持有的指定提交的租约
+func (r *JudgeQueueRepository) Renew(owner string, submissionIDs []model.SubmissionId, lease time.Duration) error {
+	if len(submissionIDs) == 0 {
+		return nil
+	}
+	return r.db.Model(&model.JudgeQueueItem{}).
+		Where("lease_owner = ? AND lease_until IS NOT NULL AND submission_id IN ?", owner, submissionIDs).
+		Update("lease_until", time.Now().Add(lease)).Error
+}
+
+// 释放 owner 持有的全部租约，使其可被立即重新认领
+func (r *JudgeQueueRepository) ReleaseAll(owner string) error {
+	return r.db.Model(&model.JudgeQueueItem{}).
+		Where("lease_owner = ?", owner).
+		Updates(map[string]any{"lease_owner": "", "lease_until": nil}).Error
+}
+
+// 统计队列中的提交数、等待认领的提交数与 owner 持有租约的提交数
+func (r *JudgeQueueRepository) Stats(owner string) (total, waiting, owned int64, err error) {
+	now := time.Now()
+	if err = r.db.Model(&model.JudgeQueueItem{}).Count(&total).Error; err != nil {
+		return
+	}
+	if err = r.db.Model(&model.JudgeQueueItem{}).
+		Where("lease_until IS NULL OR lease_until < ?", now).
+		Count(&waiting).Error; err != nil {
+		return
+	}
+	err = r.db.Model(&model.JudgeQueueItem{}).
+		Where("lease_owner = ? AND lease_until >= ?", owner, now).
+		Count(&owned).Error
+	return
+}
+
+// 评测完成后移出队列
+func (r *JudgeQueueRepository) Delete(submissionID model.SubmissionId) error {
+	return r.db.Delete(&model.JudgeQueueItem{}, submissionID).Error
+}
+
+// 将未完成评测但不在队列中的提交重新入队
+func (r *JudgeQueueRepository) RequeueUnfinished() (int64, error) {
+	result := r.db.Exec(`INSERT INTO judge_queue_items (submission_id, lease_owner, attempts, created_at)
+		SELECT s.id, '', 0, s.submitted_at FROM submissions s
+		WHERE s.verdict IN ? AND s.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM judge_queue_items q WHERE q.submission_id = s.id)`,
+		[]model.VerdictId{model.VerdictPD, model.VerdictJD})
+	return result.RowsAffected, result.Error
+}
@@ -14,6 +14,11 @@ func NewJudgementRepository(db *gorm.DB) *JudgementRepository {
 	return &JudgementRepository{db: db}
 }
 
+// 返回在事务 tx 中执行的仓库
+func (r *JudgementRepository) WithTx(tx *gorm.DB) *JudgementRepository {
+	return &JudgementRepository{db: tx}
+}
+
 func (r *JudgementRepository) Create(judgement *model.Judgement) error {
 	return r.db.Create(judgement).Error
 }
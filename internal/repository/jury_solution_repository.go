@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"reisen-be/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type JurySolutionRepository struct {
+	db *gorm.DB
+}
+
+func NewJurySolutionRepository(db *gorm.DB) *JurySolutionRepository {
+	return &JurySolutionRepository{db: db}
+}
+
+// 返回在事务 tx 中执行的仓库
+func (r *JurySolutionRepository) WithTx(tx *gorm.DB) *JurySolutionRepository {
+	return &JurySolutionRepository{db: tx}
+}
+
+func (r *JurySolutionRepository) Create(solution *model.JurySolution) error {
+	return r.db.Create(solution).Error
+}
+
+// 更新程序与预期结果，并清除已过时的验证结果
+func (r *JurySolutionRepository) Update(solution *model.JurySolution) error {
+	solution.Status = ""
+	solution.Verdict = nil
+	solution.Score = nil
+	solution.TimeUsed = nil
+	solution.MemoryUsed = nil
+	solution.CompileInfo = nil
+	solution.Testcases = nil
+	solution.Subtasks = nil
+	solution.Matched = nil
+	solution.Mismatches = nil
+	solution.JudgedAt = nil
+	solution.Fingerprint = ""
+	return r.db.Model(solution).
+		Select("name", "lang", "code", "expected", "expected_subtasks",
+			"status", "verdict", "score", "time_used", "memory_used", "compile_info",
+			"testcases", "subtasks", "matched", "mismatches", "judged_at", "fingerprint").
+		Updates(solution).Error
+}
+
+func (r *JurySolutionRepository) GetByID(id model.JurySolutionId) (*model.JurySolution, error) {
+	var solution model.JurySolution
+	if err := r.db.First(&solution, id).Error; err != nil {
+		return nil, err
+	}
+	return &solution, nil
+}
+
+func (r *JurySolutionRepository) ListByProblem(problemID model.ProblemId) ([]model.JurySolution, error) {
+	var solutions []model.JurySolution
+	err := r.db.Where("problem_id = ?", problemID).
+		Order("id ASC").
+		Find(&solutions).Error
+	return solutions, err
+}
+
+func (r *JurySolutionRepository) Delete(id model.JurySolutionId) error {
+	return r.db.Delete(&model.JurySolution{}, id).Error
+}
+
+func (r *JurySolutionRepository) UpdateStatus(id model.JurySolutionId, status model.JurySolutionStatus) error {
+	return r.db.Model(&model.JurySolution{}).
+		Where("id = ?", id).
+		Update("status", status).Error
+}
+
+// 保存验证结果，不修改程序与预期结果
+func (r *JurySolutionRepository) SaveResult(solution *model.JurySolution) error {
+	return r.db.Model(solution).
+		Select("status", "verdict", "score", "time_used", "memory_used", "compile_info",
+			"testcases", "subtasks", "matched", "mismatches", "judged_at", "fingerprint").
+		Updates(solution).Error
+}
+
+// 将上次运行中断的验证恢复为验证前的状态，返回恢复的记录数
+func (r *JurySolutionRepository) ResetUnfinished() (int64, error) {
+	result := r.db.Model(&model.JurySolution{}).
+		Where("status IN ?", []model.JurySolutionStatus{model.JurySolutionStatusPending, model.JurySolutionStatusJudging}).
+		Update("status", gorm.Expr("CASE WHEN judged_at IS NULL THEN '' ELSE ? END", model.JurySolutionStatusDone))
+	return result.RowsAffected, result.Error
+}
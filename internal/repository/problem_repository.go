@@ -14,12 +14,18 @@ func NewProblemRepository(db *gorm.DB) *ProblemRepository {
 	return &ProblemRepository{db: db}
 }
 
+// 返回在事务 tx 中执行的仓库
+func (r *ProblemRepository) WithTx(tx *gorm.DB) *ProblemRepository {
+	return &ProblemRepository{db: tx}
+}
+
 func (r *ProblemRepository) Create(problem *model.Problem) error {
 	return r.db.Create(problem).Error;
 }
 
 func (r *ProblemRepository) Update(problem *model.Problem) error {
-	return r.db.Save(problem).Error;
+	// 数据校验结果只由校验流程更新
+	return r.db.Omit("invalid_testdata").Save(problem).Error;
 }
 
 func (r *ProblemRepository) GetByID(id model.ProblemId) (*model.Problem, error) {
@@ -94,6 +100,22 @@ func (r *ProblemRepository) IncreaseSubmitCorrect(problemID model.ProblemId) err
 		Error
 }
 
+func (r *ProblemRepository) DecreaseSubmitTotal(problemID model.ProblemId) error {
+	return r.db.
+		Model(&model.Problem{}).
+		Where("id = ? AND count_total > 0", problemID).
+		Update("count_total", gorm.Expr("count_total - ?", 1)).
+		Error
+}
+
+func (r *ProblemRepository) DecreaseSubmitCorrect(problemID model.ProblemId) error {
+	return r.db.
+		Model(&model.Problem{}).
+		Where("id = ? AND count_correct > 0", problemID).
+		Update("count_correct", gorm.Expr("count_correct - ?", 1)).
+		Error
+}
+
 func (r *ProblemRepository) UpdateTestdataStatus(problemID model.ProblemId, hasData, hasConfig bool) error {
 	return r.db.Model(&model.Problem{}).
 		Where("id = ?", problemID).
@@ -103,6 +125,12 @@ func (r *ProblemRepository) UpdateTestdataStatus(problemID model.ProblemId, hasD
 		}).Error
 }
 
+func (r *ProblemRepository) UpdateTestdataValidity(problemID model.ProblemId, invalid bool) error {
+	return r.db.Model(&model.Problem{}).
+		Where("id = ?", problemID).
+		Update("invalid_testdata", invalid).Error
+}
+
 func (r *ProblemRepository) GetTestdataStatus(problemID model.ProblemId) (bool, bool, error) {
 	var problem model.Problem
 	if err := r.db.Select("has_testdata, has_config").
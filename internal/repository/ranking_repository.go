@@ -13,6 +13,11 @@ func NewRankingRepository(db *gorm.DB) *RankingRepository {
 	return &RankingRepository{db: db}
 }
 
+// 返回在事务 tx 中执行的仓库
+func (r *RankingRepository) WithTx(tx *gorm.DB) *RankingRepository {
+	return &RankingRepository{db: tx}
+}
+
 func (r *RankingRepository) Create(ranking *model.Ranking) error {
 	return r.db.Create(ranking).Error
 }
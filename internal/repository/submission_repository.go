@@ -15,10 +15,30 @@ func NewSubmissionRepository(db *gorm.DB) *SubmissionRepository {
 	return &SubmissionRepository{db: db}
 }
 
+// 返回在事务 tx 中执行的仓库
+func (r *SubmissionRepository) WithTx(tx *gorm.DB) *SubmissionRepository {
+	return &SubmissionRepository{db: tx}
+}
+
+// 在事务中执行 fn
+func (r *SubmissionRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
 func (r *SubmissionRepository) Create(submission *model.Submission) error {
 	return r.db.Create(submission).Error
 }
 
+// 创建提交记录并加入评测队列
+func (r *SubmissionRepository) CreateQueued(submission *model.Submission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(submission).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.JudgeQueueItem{SubmissionID: submission.ID}).Error
+	})
+}
+
 func (r *SubmissionRepository) Update(submission *model.Submission) error {
 	return r.db.Save(submission).Error
 }
@@ -81,4 +101,72 @@ func (r *SubmissionRepository) CheckHasPending(contestID model.ContestId) (bool,
 	}
 
 	return total > 0, nil
-}
\ No newline at end of file
+}
+
+// 评测已结束的记录的过滤条件
+func finished(query *gorm.DB) *gorm.DB {
+	return query.Where("verdict NOT IN ?", []model.VerdictId{model.VerdictPD, model.VerdictJD})
+}
+
+// 获取需要重测的记录，评测中的记录不重测
+func (r *SubmissionRepository) ListRejudgeIDs(filter *model.RejudgeFilter) ([]model.SubmissionId, error) {
+	query := finished(r.db.Model(&model.Submission{}))
+	if filter.Submission != nil {
+		query = query.Where("id = ?", *filter.Submission)
+	}
+	if filter.Problem != nil {
+		query = query.Where("problem_id = ?", *filter.Problem)
+	}
+	if filter.Contest != nil {
+		query = query.Where("contest_id = ?", *filter.Contest)
+	}
+	if filter.Verdict != nil {
+		query = query.Where("verdict = ?", *filter.Verdict)
+	}
+
+	var ids []model.SubmissionId
+	err := query.Order("submitted_at ASC, id ASC").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// 获取用户某题已评测完毕的记录，按提交顺序
+func (r *SubmissionRepository) ListFinishedByProblem(problemID model.ProblemId, userID model.UserId) ([]model.Submission, error) {
+	var submissions []model.Submission
+	err := finished(r.db).
+		Where("problem_id = ? AND user_id = ?", problemID, userID).
+		Order("submitted_at ASC, id ASC").
+		Find(&submissions).Error
+	return submissions, err
+}
+
+// 获取用户在比赛中已评测完毕的记录，按提交顺序
+func (r *SubmissionRepository) ListFinishedByContest(contestID model.ContestId, userID model.UserId) ([]model.Submission, error) {
+	var submissions []model.Submission
+	err := finished(r.db).
+		Where("contest_id = ? AND user_id = ?", contestID, userID).
+		Order("submitted_at ASC, id ASC").
+		Find(&submissions).Error
+	return submissions, err
+}
+
+// 获取比赛中某题的最早通过记录与通过人数
+func (r *SubmissionRepository) GetAcceptedStats(contestID model.ContestId, problemID model.ProblemId) (*model.Submission, int, error) {
+	query := r.db.Model(&model.Submission{}).
+		Where("contest_id = ? AND problem_id = ? AND verdict = ?", contestID, problemID, model.VerdictAC)
+
+	var solved int64
+	if err := query.Distinct("user_id").Count(&solved).Error; err != nil {
+		return nil, 0, err
+	}
+	if solved == 0 {
+		return nil, 0, nil
+	}
+
+	var first model.Submission
+	if err := r.db.Where("contest_id = ? AND problem_id = ? AND verdict = ?", contestID, problemID, model.VerdictAC).
+		Order("submitted_at ASC, id ASC").
+		First(&first).Error; err != nil {
+		return nil, 0, err
+	}
+	return &first, int(solved), nil
+}
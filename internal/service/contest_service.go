@@ -11,8 +11,11 @@ import (
 	"time"
 
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
+var ErrProblemNotInContest = errors.New("problem not found in contest")
+
 type ContestService struct {
 	contestListQuery *query.ContestListQuery
 	contestRepo      *repository.ContestRepository
@@ -69,6 +72,19 @@ func (s *ContestService) StartRankingUpdater(interval time.Duration) {
 	}()
 }
 
+// 返回在事务 tx 中更新榜单的服务副本
+func (s *ContestService) withTx(tx *gorm.DB) *ContestService {
+	return &ContestService{
+		contestListQuery: s.contestListQuery,
+		contestRepo:      s.contestRepo.WithTx(tx),
+		problemRepo:      s.problemRepo.WithTx(tx),
+		submissionRepo:   s.submissionRepo.WithTx(tx),
+		signupRepo:       s.signupRepo,
+		userRepo:         s.userRepo.WithTx(tx),
+		rankingRepo:      s.rankingRepo.WithTx(tx),
+	}
+}
+
 // 停止定时任务
 func (s *ContestService) StopRankingUpdater() {
 	if s.stopChan != nil {
@@ -231,43 +247,13 @@ func (s *ContestService) UpdateRanking(submission *model.Submission) error {
 			ContestID: contestID,
 			UserID:    submission.UserID,
 			Team:      user.Name,
-		}
-
-		// 根据赛制进行初始化
-		switch contest.Rule {
-		case model.ContestRuleACM:
-			ranking.Detail, _ = json.Marshal(model.ACMDetail{
-				Type:         "ACM",
-				TotalPenalty: 0,
-				TotalSolved:  0,
-				Problems:     make(map[model.ProblemId]model.ACMCell),
-			})
-		case model.ContestRuleOI:
-			ranking.Detail, _ = json.Marshal(model.OIDetail{
-				Type:       "OI",
-				TotalScore: 0,
-				Problems:   make(map[model.ProblemId]model.OIProblem),
-			})
-		case model.ContestRuleIOI:
-			ranking.Detail, _ = json.Marshal(model.IOIDetail{
-				Type:       "IOI",
-				TotalScore: 0,
-				Problems:   make(map[model.ProblemId]model.IOIProblem),
-			})
+			Detail:    newRankingDetail(contest.Rule),
 		}
 	}
 
 	// 查询是否是比赛内的试题
-
-	var exist bool = false
-	for _, id := range contest.Problems {
-		if id == submission.ProblemID {
-			exist = true
-			break
-		}
-	}
-	if !exist {
-		return errors.New("problem not found in contest")
+	if !contestHasProblem(contest, submission.ProblemID) {
+		return ErrProblemNotInContest
 	}
 
 	// 根据比赛规则更新信息
@@ -283,6 +269,166 @@ func (s *ContestService) UpdateRanking(submission *model.Submission) error {
 	return nil
 }
 
+func contestHasProblem(contest *model.Contest, problemID model.ProblemId) bool {
+	for _, id := range contest.Problems {
+		if id == problemID {
+			return true
+		}
+	}
+	return false
+}
+
+// 检查题目是否属于比赛
+func (s *ContestService) CheckContestProblem(contestID model.ContestId, problemID model.ProblemId) error {
+	contest, err := s.contestRepo.GetByID(contestID)
+	if err != nil {
+		return err
+	}
+	if !contestHasProblem(contest, problemID) {
+		return ErrProblemNotInContest
+	}
+	return nil
+}
+
+// 根据赛制创建空的榜单信息
+func newRankingDetail(rule model.ContestRule) datatypes.JSON {
+	var detail any
+	switch rule {
+	case model.ContestRuleACM:
+		detail = model.ACMDetail{
+			Type:         "ACM",
+			TotalPenalty: 0,
+			TotalSolved:  0,
+			Problems:     make(map[model.ProblemId]model.ACMCell),
+		}
+	case model.ContestRuleOI:
+		detail = model.OIDetail{
+			Type:       "OI",
+			TotalScore: 0,
+			Problems:   make(map[model.ProblemId]model.OIProblem),
+		}
+	case model.ContestRuleIOI:
+		detail = model.IOIDetail{
+			Type:       "IOI",
+			TotalScore: 0,
+			Problems:   make(map[model.ProblemId]model.IOIProblem),
+		}
+	}
+	data, _ := json.Marshal(detail)
+	return data
+}
+
+// 重测后根据选手在比赛中全部已评测的记录重新计算其榜单信息
+func (s *ContestService) RecomputeRanking(contestID model.ContestId, userID model.UserId) error {
+	contest, err := s.contestRepo.GetByID(contestID)
+	if err != nil {
+		return err
+	}
+
+	// 清空原有榜单信息后按提交顺序重放
+	ranking, err := s.rankingRepo.GetByID(contestID, userID)
+	if err == nil {
+		ranking.Detail = newRankingDetail(contest.Rule)
+		if err := s.rankingRepo.Update(ranking); err != nil {
+			return err
+		}
+	}
+
+	submissions, err := s.submissionRepo.ListFinishedByContest(contestID, userID)
+	if err != nil {
+		return err
+	}
+	for i := range submissions {
+		if err := s.UpdateRanking(&submissions[i]); err != nil {
+			// 不属于比赛的题目的提交不计入榜单
+			if errors.Is(err, ErrProblemNotInContest) {
+				continue
+			}
+			return err
+		}
+	}
+
+	if contest.Rule == model.ContestRuleACM {
+		return s.refreshProblemStatus(contestID, userID)
+	}
+	return nil
+}
+
+// 根据通过记录重新计算比赛各题的一血与通过人数，并修正相关选手的一血标记
+func (s *ContestService) refreshProblemStatus(contestID model.ContestId, userID model.UserId) error {
+	// 重放时已修改过比赛信息，需重新获取
+	contest, err := s.contestRepo.GetByID(contestID)
+	if err != nil {
+		return err
+	}
+	if contest.ProblemStatus == nil {
+		contest.ProblemStatus = make(map[model.ProblemId]model.ContestProblemStatus)
+	}
+
+	for _, problemID := range contest.Problems {
+		first, solved, err := s.submissionRepo.GetAcceptedStats(contestID, problemID)
+		if err != nil {
+			return err
+		}
+
+		status := contest.ProblemStatus[problemID]
+		previous := status.FirstBloodUserID
+		status.SolvedCount = solved
+		status.FirstBloodUserID = nil
+		status.FirstBloodTime = nil
+		if first != nil {
+			status.FirstBloodUserID = &first.UserID
+			status.FirstBloodTime = &first.SubmittedAt
+		}
+		contest.ProblemStatus[problemID] = status
+
+		// 需要修正一血标记的选手
+		users := []model.UserId{userID}
+		if previous != nil && *previous != userID {
+			users = append(users, *previous)
+		}
+		if first != nil && first.UserID != userID {
+			users = append(users, first.UserID)
+		}
+		for _, user := range users {
+			isFirst := first != nil && first.UserID == user
+			if err := s.setFirstBlood(contestID, user, problemID, isFirst); err != nil {
+				return err
+			}
+		}
+	}
+	return s.contestRepo.Update(contest)
+}
+
+// 设置选手某题的一血标记
+func (s *ContestService) setFirstBlood(contestID model.ContestId, userID model.UserId, problemID model.ProblemId, isFirst bool) error {
+	ranking, err := s.rankingRepo.GetByID(contestID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var detail model.ACMDetail
+	if err := json.Unmarshal(ranking.Detail, &detail); err != nil {
+		return err
+	}
+	cell, ok := detail.Problems[problemID]
+	if !ok || cell.IsFirst == isFirst {
+		return nil
+	}
+	cell.IsFirst = isFirst
+	detail.Problems[problemID] = cell
+
+	updatedDetail, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	ranking.Detail = datatypes.JSON(updatedDetail)
+	return s.rankingRepo.Update(ranking)
+}
+
 // 处理 ACM 赛制的排名信息
 func (s *ContestService) updateACMRanking(contest *model.Contest, ranking *model.Ranking, submission *model.Submission) error {
 
@@ -355,10 +501,7 @@ func (s *ContestService) updateACMRanking(contest *model.Contest, ranking *model
 		}
 		ranking.Detail = datatypes.JSON(updatedDetail)
 
-		// Save the ranking
-		if ranking.Ranking == 0 {
-			return s.rankingRepo.Create(ranking)
-		}
+		// Save the ranking, the row may already exist before it is ranked
 		return s.rankingRepo.Update(ranking)
 	}
 
@@ -404,14 +547,11 @@ func (s *ContestService) updateOIRanking(_ *model.Contest, ranking *model.Rankin
 	}
 	ranking.Detail = datatypes.JSON(updatedDetail)
 
-	// Save the ranking
-	if ranking.Ranking == 0 {
-		return s.rankingRepo.Create(ranking)
-	}
+	// Save the ranking, the row may already exist before it is ranked
 	return s.rankingRepo.Update(ranking)
 }
 
-// updateIOIRanking handles IOI contest ranking updates (similar to OI for now)
+// updateIOIRanking handles IOI contest ranking updates
 func (s *ContestService) updateIOIRanking(_ *model.Contest, ranking *model.Ranking, submission *model.Submission) error {
 
 	var detail model.IOIDetail
@@ -427,12 +567,25 @@ func (s *ContestService) updateIOIRanking(_ *model.Contest, ranking *model.Ranki
 		}
 	}
 
-	// Always update score in IOI mode
+	// IOI mode keeps the best result, per subtask if the problem has subtasks
 	problem := detail.Problems[problemID]
-	if submission.Score != nil {
-		problem.Score = *submission.Score
+	if len(submission.Subtasks) > 0 {
+		if problem.Subtasks == nil {
+			problem.Subtasks = make(map[int]int)
+		}
+		for _, subtask := range submission.Subtasks {
+			problem.Subtasks[subtask.ID] = max(problem.Subtasks[subtask.ID], subtask.Score)
+		}
+		problem.Score = 0
+		for _, score := range problem.Subtasks {
+			problem.Score += score
+		}
+	} else if submission.Score != nil {
+		problem.Score = max(problem.Score, *submission.Score)
 	}
 
+	detail.Problems[problemID] = problem
+
 	// Update totals
 	total := 0
 	for _, p := range detail.Problems {
@@ -447,10 +600,7 @@ func (s *ContestService) updateIOIRanking(_ *model.Contest, ranking *model.Ranki
 	}
 	ranking.Detail = datatypes.JSON(updatedDetail)
 
-	// Save the ranking
-	if ranking.Ranking == 0 {
-		return s.rankingRepo.Create(ranking)
-	}
+	// Save the ranking, the row may already exist before it is ranked
 	return s.rankingRepo.Update(ranking)
 }
 
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"reisen-be/internal/config"
+	"reisen-be/internal/filesystem"
+	"reisen-be/internal/model"
+	"reisen-be/internal/repository"
+	"reisen-be/internal/service/judge"
+	"reisen-be/internal/websocket"
+	"sync"
+	"time"
+)
+
+const (
+	customTestTimeout    = time.Minute // 单次自测（编译与运行）的最长时间
+	customTestInputLimit = 1 << 20     // 自测输入的长度上限
+)
+
+var (
+	ErrCustomTestTooFrequent  = errors.New("custom test is too frequent, please try again later")
+	ErrCustomTestInputTooLong = errors.New("custom test input is too long")
+)
+
+// 自测服务：以用户提供的输入编译并运行代码，结果通过 websocket 推送，不产生评测记录
+type CustomTestService struct {
+	problemRepo       *repository.ProblemRepository
+	problemFilesystem *filesystem.ProblemFilesystem
+	sandboxes         *judge.SandboxPool
+	compiler          *judge.Compiler
+	runner            *judge.Runner
+	customTestWs      *websocket.CustomTestWs
+
+	interval time.Duration              // 同一用户两次自测的最短间隔
+	running  map[model.UserId]bool      // 正在进行自测的用户
+	lastRun  map[model.UserId]time.Time // 用户上次开始自测的时间
+	mu       sync.Mutex
+}
+
+func NewCustomTestService(
+	problemRepo *repository.ProblemRepository,
+	problemFilesystem *filesystem.ProblemFilesystem,
+	sandboxes *judge.SandboxPool,
+	languages *config.LanguageRegistry,
+	customTestWs *websocket.CustomTestWs,
+	interval time.Duration,
+) *CustomTestService {
+	return &CustomTestService{
+		problemRepo:       problemRepo,
+		problemFilesystem: problemFilesystem,
+		sandboxes:         sandboxes,
+		compiler:          judge.NewCompiler(languages, nil),
+		runner:            judge.NewRunner(languages, nil),
+		customTestWs:      customTestWs,
+		interval:          interval,
+		running:           make(map[model.UserId]bool),
+		lastRun:           make(map[model.UserId]time.Time),
+	}
+}
+
+// 开始自测，返回用于订阅结果的自测编号
+func (s *CustomTestService) Start(req *model.CustomTestRequest, userID model.UserId) (string, error) {
+	if len(req.Input) > customTestInputLimit {
+		return "", ErrCustomTestInputTooLong
+	}
+	problem, err := s.problemRepo.GetByID(req.Problem)
+	if err != nil {
+		return "", err
+	}
+	if problem.Type == model.ProblemTypeInteractive {
+		return "", errors.New("custom test is not supported for interactive problems")
+	}
+
+	// 使用题目的时空限制与文件输入输出设置，没有测试数据时仅使用题目信息
+	judgeConfig := model.JudgeConfig{}
+	if config, err := s.problemFilesystem.GetJudgeConfig(req.Problem); err == nil {
+		judgeConfig = *config
+	}
+	judgeConfig.TimeLimit = problem.LimitTime
+	judgeConfig.MemoryLimit = problem.LimitMemory
+	judgeConfig.Type = problem.Type
+
+	task := &model.JudgeTask{
+		Submission: model.Submission{
+			SubmissionCore: model.SubmissionCore{
+				ProblemID: req.Problem,
+				UserID:    userID,
+				Lang:      req.Lang,
+			},
+			Code: req.Code,
+		},
+		Config: judgeConfig,
+	}
+	limits, err := s.runner.Limits(task)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.acquire(userID); err != nil {
+		return "", err
+	}
+
+	id, err := newCustomTestID()
+	if err != nil {
+		s.release(userID)
+		return "", err
+	}
+
+	result := model.CustomTestResult{
+		ID:      id,
+		Verdict: model.VerdictPD,
+		Limits:  limits,
+	}
+	s.customTestWs.Broadcast(id, result, false)
+
+	go func() {
+		defer s.release(userID)
+		s.run(task, req.Input, &result)
+		s.customTestWs.Broadcast(id, result, true)
+	}()
+	return id, nil
+}
+
+// 编译并运行，结果写入 result
+func (s *CustomTestService) run(task *model.JudgeTask, input string, result *model.CustomTestResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), customTestTimeout)
+	defer cancel()
+	ctx = judge.WithFairKey(ctx, result.ID)
+
+	fail := func(err error) {
+		message := err.Error()
+		result.Verdict = model.VerdictUKE
+		result.Message = &message
+	}
+
+	sandbox, err := s.sandboxes.Acquire()
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer s.sandboxes.Release(sandbox)
+
+	result.Verdict = model.VerdictJD
+	s.customTestWs.Broadcast(result.ID, *result, false)
+
+	fileId, compileInfo, err := s.compiler.Compile(ctx, sandbox, task)
+	result.CompileInfo = compileInfo
+	if err != nil {
+		// 没有编译信息说明请求沙箱失败，而非编译错误
+		if compileInfo == nil {
+			fail(err)
+			return
+		}
+		result.Verdict = model.VerdictCE
+		return
+	}
+	defer s.compiler.DeleteFile(context.Background(), sandbox, fileId)
+
+	testResult, output, stderr, err := s.runner.RunCustom(ctx, sandbox, task, fileId, input)
+	if err != nil {
+		log.Printf("Custom test %s failed: %v", result.ID, err)
+		fail(err)
+		return
+	}
+	result.Verdict = testResult.Verdict
+	result.Time = testResult.Time
+	result.Memory = testResult.Memory
+	result.Output = &output
+	result.Stderr = &stderr
+	result.Message = testResult.Checker
+}
+
+// 检查用户是否可以开始新的自测：同一时间只能进行一次，且两次间隔不少于 interval
+func (s *CustomTestService) acquire(userID model.UserId) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running[userID] || time.Since(s.lastRun[userID]) < s.interval {
+		return ErrCustomTestTooFrequent
+	}
+	s.running[userID] = true
+	s.lastRun[userID] = time.Now()
+	return nil
+}
+
+func (s *CustomTestService) release(userID model.UserId) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.running, userID)
+	// 间隔已过的记录不再需要
+	for id, t := range s.lastRun {
+		if time.Since(t) >= s.interval {
+			delete(s.lastRun, id)
+		}
+	}
+}
+
+// 生成随机的自测编号，订阅结果时无需登录，编号不可猜测
+func newCustomTestID() (string, error) {
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
@@ -1,40 +1,101 @@
 package judge
 
 import (
-    "bytes"
+    "bufio"
+    "context"
     "fmt"
-    "os/exec"
+    "io"
+    "math"
+    "os"
 	"reisen-be/internal/model"
+    "strconv"
     "strings"
 )
 
+// 校验请求
+type CheckRequest struct {
+    InputFile    string // 输入文件（绝对位置）
+    AnswerFile   string // 答案文件（绝对位置）
+    OutputFileId string // 选手输出（沙箱中缓存的文件）
+}
+
+// 校验结果
+type CheckResult struct {
+    Verdict model.VerdictId
+    Points  float64 // 得分比例，取值 [0, 1]
+    Message string
+}
+
 type Checker interface {
-    Check(output, expectedOutput string) (bool, string)
+    Check(ctx context.Context, req *CheckRequest) (*CheckResult, error)
+}
+
+// 仅比较选手输出与答案的内置判分器，以流的方式读取，不将输出整体载入内存
+type TextChecker interface {
+    Check(output, answer io.Reader) (bool, string, error)
+}
+
+type builtinChecker struct {
+    text    TextChecker
+    sandbox Sandbox
+}
+
+func (c *builtinChecker) Check(ctx context.Context, req *CheckRequest) (*CheckResult, error) {
+    answer, err := os.Open(req.AnswerFile)
+    if err != nil {
+        return nil, err
+    }
+    defer answer.Close()
+
+    output, err := c.sandbox.OpenFile(ctx, req.OutputFileId)
+    if err != nil {
+        return nil, err
+    }
+    defer output.Close()
+
+    passed, message, err := c.text.Check(output, answer)
+    if err != nil {
+        return nil, err
+    }
+    if passed {
+        return &CheckResult{Verdict: model.VerdictAC, Points: 1, Message: message}, nil
+    }
+    return &CheckResult{Verdict: model.VerdictWA, Points: 0, Message: message}, nil
 }
 
 type StrictChecker struct{}
 
-func (c *StrictChecker) Check(output, answer string) (bool, string) {
+func (c *StrictChecker) Check(output, answer io.Reader) (bool, string, error) {
+    out := bufio.NewReader(output)
+    ans := bufio.NewReader(answer)
+
     row := 1
     col := 1
-    for i := range(len(answer) + 1) {
-        outEof := i >= len(output)
-        ansEof := i >= len(answer)
+    count := 0
+    for {
+        o, outErr := out.ReadByte()
+        a, ansErr := ans.ReadByte()
+        if err := firstError(outErr, ansErr); err != nil {
+            return false, "", err
+        }
+        outEof := outErr == io.EOF
+        ansEof := ansErr == io.EOF
         if outEof != ansEof {
             if outEof {
-                return false, "Unexpected end of file."
+                return false, "Unexpected end of file.", nil
             } else 
             if ansEof {
-                return false, fmt.Sprintf("Expect end of file, read '%c'.", output[i])
+                return false, fmt.Sprintf("Expect end of file, read '%c'.", o), nil
             }
         } else 
         if ansEof {
             break
         } else
-        if output[i] != answer[i] {
-            return false, fmt.Sprintf("On line %d column %d, read '%c', expected '%c'.", row, col, output[i], answer[i])
+        if o != a {
+            return false, fmt.Sprintf("On line %d column %d, read '%c', expected '%c'.", row, col, o, a), nil
         } else {
-            if answer[i] == '\n' {
+            count = count + 1
+            if a == '\n' {
                 row = row + 1
                 col = 1
             } else {
@@ -42,71 +103,378 @@ func (c *StrictChecker) Check(output, answer string) (bool, string) {
             }
         }
     }
-    return true, fmt.Sprintf("OK, %d character(s)", len(answer))
+    return true, fmt.Sprintf("OK, %d character(s)", count), nil
+}
+
+// 返回第一个非 EOF 的读取错误
+func firstError(errs ...error) error {
+    for _, err := range errs {
+        if err != nil && err != io.EOF {
+            return err
+        }
+    }
+    return nil
+}
+
+// 按行读取，去除行末空白与所有 \r
+type lineReader struct {
+    r *bufio.Reader
+}
+
+func newLineReader(r io.Reader) *lineReader {
+    return &lineReader{r: bufio.NewReader(r)}
+}
+
+// 读取下一行，没有更多行时返回 io.EOF
+func (l *lineReader) next() (string, error) {
+    line, err := l.r.ReadString('\n')
+    if err == io.EOF && line == "" {
+        return "", io.EOF
+    }
+    if err != nil && err != io.EOF {
+        return "", err
+    }
+    line = strings.TrimSuffix(line, "\n")
+    return strings.TrimRight(strings.ReplaceAll(line, "\r", ""), " \t"), nil
+}
+
+// 截断过长的内容，用于判分信息
+func abbreviate(s string) string {
+    const limit = 64
+    if len(s) > limit {
+        return s[:limit] + "..."
+    }
+    return s
 }
 
 type LooseChecker struct{}
 
-func (c *LooseChecker) Check(output, answer string) (bool, string) {
-    cleanLines := func(s string) []string {
-        lines := strings.Split(s, "\n")
-        for i := range lines {
-            lines[i] = strings.TrimRight(strings.ReplaceAll(lines[i], "\r", ""), " \t")
+func (c *LooseChecker) Check(output, answer io.Reader) (bool, string, error) {
+    out := newLineReader(output)
+    ans := newLineReader(answer)
+
+    // 末尾的空行不计入行数
+    outCount, ansCount := 0, 0
+    charCount := 0
+    for i := 1; ; i++ {
+        outLine, outErr := out.next()
+        ansLine, ansErr := ans.next()
+        if err := firstError(outErr, ansErr); err != nil {
+            return false, "", err
         }
-        // 移除末尾的空行
-        for len(lines) > 0 && lines[len(lines)-1] == "" {
-            lines = lines[:len(lines)-1]
+        if outErr == io.EOF && ansErr == io.EOF {
+            break
+        }
+        if outErr == nil && outLine != "" {
+            outCount = i
+        }
+        if ansErr == nil && ansLine != "" {
+            ansCount = i
+        }
+        if outErr == nil && ansErr == nil && outLine != ansLine {
+            return false, fmt.Sprintf("Line %d differs.\nOutput:  \"%s\"\nAnswer:  \"%s\"", i, abbreviate(outLine), abbreviate(ansLine)), nil
         }
-        return lines
+        charCount += len(ansLine)
     }
+    if outCount != ansCount {
+        return false, fmt.Sprintf("Line count mismatch. Output has %d line(s), answer has %d line(s).", outCount, ansCount), nil
+    }
+    return true, fmt.Sprintf("OK, %d character(s)", charCount), nil
+}
+
+// 输出中的一个记号（以空白字符分隔）
+type token struct {
+    text string
+    line int
+    col  int
+}
+
+// 逐个读取记号，同时记录每个记号所在的行列
+type tokenReader struct {
+    r    *bufio.Reader
+    line int
+    col  int
+}
 
-    outLines := cleanLines(output)
-    ansLines := cleanLines(answer)
+func newTokenReader(r io.Reader) *tokenReader {
+    return &tokenReader{r: bufio.NewReader(r), line: 1, col: 1}
+}
 
-    minLen := len(outLines)
-    if len(ansLines) < minLen {
-        minLen = len(ansLines)
+func isSpace(b byte) bool {
+    return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// 读取下一个记号，没有更多记号时返回 io.EOF
+func (t *tokenReader) next() (token, error) {
+    var text []byte
+    tok := token{}
+    for {
+        b, err := t.r.ReadByte()
+        if err == io.EOF && len(text) > 0 {
+            break
+        }
+        if err != nil {
+            return token{}, err
+        }
+        if isSpace(b) {
+            if b == '\n' {
+                t.line = t.line + 1
+                t.col = 1
+            } else {
+                t.col = t.col + 1
+            }
+            if len(text) > 0 {
+                break
+            }
+            continue
+        }
+        if len(text) == 0 {
+            tok.line = t.line
+            tok.col = t.col
+        }
+        text = append(text, b)
+        t.col = t.col + 1
     }
-    for i := 0; i < minLen; i++ {
-        if outLines[i] != ansLines[i] {
-            return false, fmt.Sprintf("Line %d differs.\nOutput:  \"%s\"\nAnswer:  \"%s\"", i+1, outLines[i], ansLines[i])
+    tok.text = string(text)
+    return tok, nil
+}
+
+// 逐个比较选手输出与答案中的记号，compare 返回记号是否相同及附加的说明
+func compareTokens(output, answer io.Reader, compare func(out, ans string) (bool, string)) (bool, string, error) {
+    outTokens := newTokenReader(output)
+    ansTokens := newTokenReader(answer)
+
+    count := 0
+    for {
+        out, outErr := outTokens.next()
+        ans, ansErr := ansTokens.next()
+        if err := firstError(outErr, ansErr); err != nil {
+            return false, "", err
+        }
+        if ansErr == io.EOF {
+            if outErr != io.EOF {
+                return false, fmt.Sprintf("Expect end of file, read '%s'.", abbreviate(out.text)), nil
+            }
+            break
+        }
+        if outErr == io.EOF {
+            return false, "Unexpected end of file.", nil
+        }
+        count = count + 1
+
+        if same, detail := compare(out.text, ans.text); !same {
+            return false, fmt.Sprintf("On line %d column %d, read '%s', expected '%s'%s.", out.line, out.col, abbreviate(out.text), abbreviate(ans.text), detail), nil
         }
     }
-    if len(outLines) != len(ansLines) {
-        return false, fmt.Sprintf("Line count mismatch. Output has %d line(s), answer has %d line(s).", len(outLines), len(ansLines))
+    return true, fmt.Sprintf("OK, %d token(s)", count), nil
+}
+
+// 忽略空白字符差异，逐个比较记号
+type TokenChecker struct {
+    IgnoreCase bool // 是否忽略大小写
+}
+
+func (c *TokenChecker) Check(output, answer io.Reader) (bool, string, error) {
+    return compareTokens(output, answer, func(out, ans string) (bool, string) {
+        if c.IgnoreCase {
+            return strings.EqualFold(out, ans), ""
+        }
+        return out == ans, ""
+    })
+}
+
+// 逐个比较记号，数值记号在绝对误差或相对误差范围内即视为相同
+type FloatChecker struct {
+    Absolute float64 // 允许的绝对误差
+    Relative float64 // 允许的相对误差（相对于答案）
+}
+
+func (c *FloatChecker) Check(output, answer io.Reader) (bool, string, error) {
+    return compareTokens(output, answer, func(out, ans string) (bool, string) {
+        ansValue, ansParseErr := strconv.ParseFloat(ans, 64)
+        outValue, outParseErr := strconv.ParseFloat(out, 64)
+        if ansParseErr != nil || outParseErr != nil {
+            return out == ans, ""
+        }
+        if ansValue == outValue {
+            return true, ""
+        }
+        // nan 只与 nan 相等，无穷大只与同号的无穷大相等
+        if math.IsNaN(ansValue) || math.IsNaN(outValue) {
+            return math.IsNaN(ansValue) && math.IsNaN(outValue), ""
+        }
+        if math.IsInf(ansValue, 0) || math.IsInf(outValue, 0) {
+            return false, ""
+        }
+        diff := math.Abs(ansValue - outValue)
+        tolerance := math.Max(c.Absolute, c.Relative*math.Abs(ansValue))
+        if diff <= tolerance {
+            return true, ""
+        }
+        return false, fmt.Sprintf(", error %g exceeds %g", diff, tolerance)
+    })
+}
+
+// 不考虑行的顺序，比较选手输出与答案的各行（忽略行末空白与空行）
+type UnorderedChecker struct{}
+
+func (c *UnorderedChecker) Check(output, answer io.Reader) (bool, string, error) {
+    // 答案各行出现的次数，选手输出需逐行抵消
+    expected := map[string]int{}
+    ans := newLineReader(answer)
+    ansCount := 0
+    for {
+        line, err := ans.next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return false, "", err
+        }
+        if line != "" {
+            expected[line]++
+            ansCount++
+        }
     }
-    charCount := 0
-    for _, line := range ansLines {
-        charCount += len(line)
+
+    out := newLineReader(output)
+    outCount := 0
+    for i := 1; ; i++ {
+        line, err := out.next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return false, "", err
+        }
+        if line == "" {
+            continue
+        }
+        if expected[line] == 0 {
+            return false, fmt.Sprintf("On line %d, read \"%s\", which is not expected.", i, abbreviate(line)), nil
+        }
+        expected[line]--
+        outCount++
     }
-    return true, fmt.Sprintf("OK, %d character(s)", charCount)
+    if outCount != ansCount {
+        return false, fmt.Sprintf("Line count mismatch. Output has %d line(s), answer has %d line(s).", outCount, ansCount), nil
+    }
+    return true, fmt.Sprintf("OK, %d line(s)", ansCount), nil
 }
 
-type CustomChecker struct {
-    CheckerPath string
+// testlib 格式的判分器，在沙箱中以 checker input output answer 的形式运行
+type TestlibChecker struct {
+    program *Program
 }
 
-func (c *CustomChecker) Check(output, expectedOutput string) (bool, string) {
-    cmd := exec.Command(c.CheckerPath, expectedOutput)
-    cmd.Stdin = strings.NewReader(output)
-    
-    var out bytes.Buffer
-    cmd.Stdout = &out
-    cmd.Stderr = &out
-    
-    if err := cmd.Run(); err != nil {
-        return false, out.String()
+func (c *TestlibChecker) Check(ctx context.Context, req *CheckRequest) (*CheckResult, error) {
+    results, err := c.program.Run(ctx, func(fileId string) *model.RunRequestPayload {
+        return checkerPayload(fileId, req)
+    })
+    if err != nil {
+        return nil, err
     }
-    
-    return true, out.String()
+    return parseTestlibResult(&results[0]), nil
 }
 
-func NewChecker(config model.JudgeConfig) (Checker, error) {
+func checkerPayload(fileId string, req *CheckRequest) *model.RunRequestPayload {
+    return &model.RunRequestPayload{
+        Cmd: []model.Cmd{
+            {
+                Args:        []string{"./checker", "input", "output", "answer"},
+                Env:         []string{"PATH=/usr/bin:/bin"},
+                CPULimit:    10_000_000_000,    // 10s
+                ClockLimit:  20_000_000_000,    // 20s
+                MemoryLimit: 512 * 1024 * 1024, // 512MB
+                ProcLimit:   50,
+                Files: []any{
+                    map[string]any{"content": ""},
+                    map[string]any{"name": "stdout", "max": 10240},
+                    map[string]any{"name": "stderr", "max": 10240},
+                },
+                CopyIn: map[string]any{
+                    "checker": map[string]any{"fileId": fileId},
+                    "input":   map[string]any{"src": req.InputFile},
+                    "answer":  map[string]any{"src": req.AnswerFile},
+                    "output":  map[string]any{"fileId": req.OutputFileId},
+                },
+                CopyOut: []string{"stdout", "stderr"},
+            },
+        },
+    }
+}
+
+// 按 testlib 退出码解释程序运行结果：0 AC，1 WA，2 PE，3 FAIL，7 部分分
+func parseTestlibResult(result *GoJudgeResult) *CheckResult {
+    message := strings.TrimSpace(result.Files["stderr"])
+
+    switch result.Status {
+    case model.StatusAccepted:
+        return &CheckResult{Verdict: model.VerdictAC, Points: 1, Message: message}
+    case model.StatusNonzeroExitStatus:
+        // 按退出码区分结果
+    default:
+        return &CheckResult{Verdict: model.VerdictUKE, Message: fmt.Sprintf("Checker failed: %s. %s", result.Status, message)}
+    }
+
+    switch result.ExitStatus {
+    case 1:
+        return &CheckResult{Verdict: model.VerdictWA, Message: message}
+    case 2:
+        return &CheckResult{Verdict: model.VerdictPE, Message: message}
+    case 7:
+        // testlib 的 quitp 输出形如 "points 0.5 message"
+        fields := strings.Fields(message)
+        if len(fields) >= 2 && fields[0] == "points" {
+            if points, err := strconv.ParseFloat(fields[1], 64); err == nil {
+                points = math.Max(0, math.Min(1, points))
+                verdict := model.VerdictPC
+                if points >= 1 {
+                    verdict = model.VerdictAC
+                } else if points <= 0 {
+                    verdict = model.VerdictWA
+                }
+                return &CheckResult{Verdict: verdict, Points: points, Message: message}
+            }
+        }
+        return &CheckResult{Verdict: model.VerdictUKE, Message: fmt.Sprintf("Checker returned malformed points. %s", message)}
+    default:
+        return &CheckResult{Verdict: model.VerdictUKE, Message: fmt.Sprintf("Checker failed with exit code %d. %s", result.ExitStatus, message)}
+    }
+}
+
+// float 判分器未指定误差时的默认绝对误差
+const defaultEpsilon = 1e-6
+
+// 根据题目评测配置创建判分器，root 为题目目录
+func NewChecker(ctx context.Context, config model.JudgeConfig, root string, sb Sandbox, programs *ProgramCache) (Checker, error) {
     switch config.CheckerType {
     case "strict":
-        return &StrictChecker{}, nil
-    case "loose":
-        return &LooseChecker{}, nil
+        return &builtinChecker{text: &StrictChecker{}, sandbox: sb}, nil
+    case "loose", "":
+        // 未配置时保持原先的宽松比较
+        return &builtinChecker{text: &LooseChecker{}, sandbox: sb}, nil
+    case "token":
+        return &builtinChecker{text: &TokenChecker{}, sandbox: sb}, nil
+    case "icase":
+        return &builtinChecker{text: &TokenChecker{IgnoreCase: true}, sandbox: sb}, nil
+    case "float":
+        absolute, relative := config.Epsilon, config.RelativeEpsilon
+        if absolute <= 0 && relative <= 0 {
+            absolute = defaultEpsilon
+        }
+        return &builtinChecker{text: &FloatChecker{Absolute: absolute, Relative: relative}, sandbox: sb}, nil
+    case "unordered":
+        return &builtinChecker{text: &UnorderedChecker{}, sandbox: sb}, nil
+    case "custom":
+        if config.Checker == nil || config.Checker.Source == "" {
+            return nil, fmt.Errorf("custom checker is not configured")
+        }
+        program, err := programs.Load(ctx, sb, root, *config.Checker)
+        if err != nil {
+            return nil, err
+        }
+        return &TestlibChecker{program: program}, nil
     default:
         return nil, fmt.Errorf("unknown checker type: %s", config.CheckerType)
     }
@@ -0,0 +1,135 @@
+package judge
+
+import (
+	"math"
+	"reisen-be/internal/model"
+	"strings"
+	"testing"
+)
+
+type textCheckerCase struct {
+	name   string
+	output string
+	answer string
+	want   bool
+}
+
+func runTextCheckerCases(t *testing.T, checker TextChecker, tests []textCheckerCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, message, err := checker.Check(strings.NewReader(tt.output), strings.NewReader(tt.answer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %v (%s), want %v", got, message, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictChecker(t *testing.T) {
+	runTextCheckerCases(t, &StrictChecker{}, []textCheckerCase{
+		{"identical", "1 2\n3\n", "1 2\n3\n", true},
+		{"empty", "", "", true},
+		{"different character", "1 2\n4\n", "1 2\n3\n", false},
+		{"missing trailing newline", "1 2\n3", "1 2\n3\n", false},
+		{"extra trailing space", "1 2 \n3\n", "1 2\n3\n", false},
+		{"carriage return", "1 2\r\n3\r\n", "1 2\n3\n", false},
+	})
+}
+
+func TestLooseChecker(t *testing.T) {
+	runTextCheckerCases(t, &LooseChecker{}, []textCheckerCase{
+		{"identical", "1 2\n3\n", "1 2\n3\n", true},
+		{"trailing spaces", "1 2  \n3\t\n", "1 2\n3\n", true},
+		{"carriage returns", "1 2\r\n3\r\n", "1 2\n3\n", true},
+		{"trailing blank lines", "1 2\n3\n\n\n", "1 2\n3", true},
+		{"missing line", "1 2\n", "1 2\n3\n", false},
+		{"extra line", "1 2\n3\n4\n", "1 2\n3\n", false},
+		{"leading spaces matter", " 1 2\n3\n", "1 2\n3\n", false},
+		{"inner spaces matter", "1  2\n3\n", "1 2\n3\n", false},
+	})
+}
+
+func TestTokenChecker(t *testing.T) {
+	runTextCheckerCases(t, &TokenChecker{}, []textCheckerCase{
+		{"identical", "1 2 3\n", "1 2 3\n", true},
+		{"whitespace differs", "1\n2\t\t3", "1 2 3\n", true},
+		{"different token", "1 2 4\n", "1 2 3\n", false},
+		{"missing token", "1 2\n", "1 2 3\n", false},
+		{"extra token", "1 2 3 4\n", "1 2 3\n", false},
+		{"case matters", "YES\n", "yes\n", false},
+	})
+	runTextCheckerCases(t, &TokenChecker{IgnoreCase: true}, []textCheckerCase{
+		{"case ignored", "YES no\n", "yes NO\n", true},
+		{"different token ignoring case", "YES\n", "no\n", false},
+	})
+}
+
+func TestFloatChecker(t *testing.T) {
+	runTextCheckerCases(t, &FloatChecker{Absolute: 1e-6}, []textCheckerCase{
+		{"within absolute error", "1.0000001\n", "1\n", true},
+		{"beyond absolute error", "1.00001\n", "1\n", false},
+		{"non-numeric tokens compared exactly", "abc 1\n", "abc 1.0\n", true},
+		{"non-numeric tokens differ", "abd 1\n", "abc 1\n", false},
+		{"nan matches nan", "nan\n", "NaN\n", true},
+		{"nan does not match a number", "nan\n", "0\n", false},
+		{"number does not match nan", "0\n", "nan\n", false},
+		{"inf matches inf", "inf\n", "+Inf\n", true},
+		{"inf does not match -inf", "-inf\n", "inf\n", false},
+		{"inf does not match a large number", "1e308\n", "inf\n", false},
+	})
+	runTextCheckerCases(t, &FloatChecker{Relative: 1e-6}, []textCheckerCase{
+		{"within relative error", "1000000.5\n", "1000000\n", true},
+		{"beyond relative error", "1000002\n", "1000000\n", false},
+	})
+}
+
+func TestUnorderedChecker(t *testing.T) {
+	runTextCheckerCases(t, &UnorderedChecker{}, []textCheckerCase{
+		{"same order", "a\nb\nc\n", "a\nb\nc\n", true},
+		{"different order", "c\na\nb\n", "a\nb\nc\n", true},
+		{"blank lines and trailing spaces ignored", "c \n\na\nb\n\n", "a\nb\nc\n", true},
+		{"duplicates counted", "a\na\nb\n", "a\nb\nb\n", false},
+		{"missing line", "a\nb\n", "a\nb\nc\n", false},
+		{"unexpected line", "a\nb\nd\n", "a\nb\nc\n", false},
+	})
+}
+
+func TestParseTestlibResult(t *testing.T) {
+	tests := []struct {
+		name        string
+		result      GoJudgeResult
+		wantVerdict model.VerdictId
+		wantPoints  float64
+	}{
+		{"accepted", GoJudgeResult{Status: model.StatusAccepted}, model.VerdictAC, 1},
+		{"wrong answer", GoJudgeResult{Status: model.StatusNonzeroExitStatus, ExitStatus: 1}, model.VerdictWA, 0},
+		{"presentation error", GoJudgeResult{Status: model.StatusNonzeroExitStatus, ExitStatus: 2}, model.VerdictPE, 0},
+		{"fail", GoJudgeResult{Status: model.StatusNonzeroExitStatus, ExitStatus: 3}, model.VerdictUKE, 0},
+		{"partial points", pointsResult("points 0.25 ok"), model.VerdictPC, 0.25},
+		{"full points", pointsResult("points 1 ok"), model.VerdictAC, 1},
+		{"points clamped", pointsResult("points 1.5"), model.VerdictAC, 1},
+		{"zero points", pointsResult("points 0"), model.VerdictWA, 0},
+		{"malformed points", pointsResult("partially correct"), model.VerdictUKE, 0},
+		{"checker crashed", GoJudgeResult{Status: model.StatusTimeLimitExceeded}, model.VerdictUKE, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseTestlibResult(&tt.result)
+			if got.Verdict != tt.wantVerdict || math.Abs(got.Points-tt.wantPoints) > 1e-9 {
+				t.Fatalf("got %s %g (%s), want %s %g", got.Verdict, got.Points, got.Message, tt.wantVerdict, tt.wantPoints)
+			}
+		})
+	}
+}
+
+func pointsResult(stderr string) GoJudgeResult {
+	return GoJudgeResult{
+		Status:     model.StatusNonzeroExitStatus,
+		ExitStatus: 7,
+		Files:      map[string]string{"stderr": stderr},
+	}
+}
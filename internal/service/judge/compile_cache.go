@@ -0,0 +1,223 @@
+package judge
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reisen-be/internal/model"
+	"sync"
+	"time"
+)
+
+// 选手程序的编译结果
+type compileEntry struct {
+	key      string
+	sandbox  Sandbox
+	fileId   string
+	info     *model.CompileInfo
+	err      error
+	ready    chan struct{} // 编译完成后关闭
+	done     bool          // 编译是否已完成
+	refs     int           // 正在使用该编译结果的评测任务数
+	expireAt time.Time
+	evicted  bool // 已移出缓存，引用归零后删除沙箱中的文件
+	elem     *list.Element
+}
+
+// 按（语言配置, 源代码）缓存选手程序在各沙箱后端的编译结果，包括编译错误。
+// 超出容量时淘汰最久未使用的结果，超过有效期的结果也会被清除。
+type CompileCache struct {
+	compiler *Compiler
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*compileEntry
+	lru      *list.List // 越靠前越近使用
+	mu       sync.Mutex
+}
+
+// capacity 不大于 0 时不缓存，每次均重新编译
+func NewCompileCache(compiler *Compiler, capacity int, ttl time.Duration) *CompileCache {
+	return &CompileCache{
+		compiler: compiler,
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*compileEntry),
+		lru:      list.New(),
+	}
+}
+
+// 编译提交的代码，相同的编译结果直接复用。使用完毕后需调用返回的 release
+func (c *CompileCache) Compile(ctx context.Context, sb Sandbox, task *model.JudgeTask) (string, *model.CompileInfo, func(), error) {
+	if c.capacity <= 0 {
+		fileId, info, err := c.compiler.Compile(ctx, sb, task)
+		release := func() {
+			if fileId != "" {
+				c.compiler.DeleteFile(context.Background(), sb, fileId)
+			}
+		}
+		return fileId, info, release, err
+	}
+
+	key, err := c.key(sb, task.Lang, task.Code)
+	if err != nil {
+		return "", nil, func() {}, err
+	}
+
+	c.mu.Lock()
+	c.removeExpired()
+	if entry, ok := c.entries[key]; ok {
+		entry.refs++
+		c.lru.MoveToFront(entry.elem)
+		c.mu.Unlock()
+		return c.wait(ctx, entry)
+	}
+
+	entry := &compileEntry{
+		key:     key,
+		sandbox: sb,
+		ready:   make(chan struct{}),
+		refs:    1,
+	}
+	entry.elem = c.lru.PushFront(entry)
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	fileId, info, err := c.compiler.Compile(ctx, sb, task)
+
+	c.mu.Lock()
+	entry.fileId, entry.info, entry.err = fileId, info, err
+	entry.expireAt = time.Now().Add(c.ttl)
+	entry.done = true
+	// 沙箱请求失败不缓存，下次重新编译
+	if info == nil {
+		c.remove(entry)
+	}
+	close(entry.ready)
+	c.evictOverflow()
+	c.mu.Unlock()
+
+	return fileId, info, func() { c.release(entry) }, err
+}
+
+// 等待其他评测任务完成相同代码的编译
+func (c *CompileCache) wait(ctx context.Context, entry *compileEntry) (string, *model.CompileInfo, func(), error) {
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		c.release(entry)
+		return "", nil, func() {}, ctx.Err()
+	}
+	release := func() { c.release(entry) }
+	if entry.info == nil {
+		release()
+		return "", nil, func() {}, entry.err
+	}
+	return entry.fileId, entry.info, release, entry.err
+}
+
+// 定期清除过期的编译结果
+func (c *CompileCache) StartCleaner(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.mu.Lock()
+				c.removeExpired()
+				c.mu.Unlock()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// 清空缓存并删除沙箱中的编译结果
+func (c *CompileCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if entry := elem.Value.(*compileEntry); entry.done {
+			c.remove(entry)
+		}
+		elem = next
+	}
+}
+
+// 缓存键：沙箱后端、语言配置与源代码的摘要，语言配置变化后不再复用旧结果
+func (c *CompileCache) key(sb Sandbox, lang model.CodeLangId, code string) (string, error) {
+	langConfig := c.compiler.languages.Get(lang)
+	if langConfig == nil {
+		return "", fmt.Errorf("unsupported language: %s", lang)
+	}
+	data, err := json.Marshal(langConfig)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write(data)
+	h.Write([]byte{0})
+	h.Write([]byte(code))
+	return sb.Name() + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *CompileCache) release(entry *compileEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		c.deleteFile(entry)
+	}
+}
+
+// 将编译结果移出缓存，无人使用时立即删除沙箱中的文件
+func (c *CompileCache) remove(entry *compileEntry) {
+	if entry.evicted {
+		return
+	}
+	entry.evicted = true
+	delete(c.entries, entry.key)
+	c.lru.Remove(entry.elem)
+	if entry.refs == 0 {
+		c.deleteFile(entry)
+	}
+}
+
+func (c *CompileCache) removeExpired() {
+	now := time.Now()
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		entry := elem.Value.(*compileEntry)
+		if entry.done && now.After(entry.expireAt) {
+			c.remove(entry)
+		}
+		elem = prev
+	}
+}
+
+// 淘汰最久未使用的编译结果，直到不超过容量
+func (c *CompileCache) evictOverflow() {
+	for elem := c.lru.Back(); elem != nil && c.lru.Len() > c.capacity; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*compileEntry); entry.done {
+			c.remove(entry)
+		}
+		elem = prev
+	}
+}
+
+func (c *CompileCache) deleteFile(entry *compileEntry) {
+	if entry.fileId == "" {
+		return
+	}
+	go c.compiler.DeleteFile(context.Background(), entry.sandbox, entry.fileId)
+}
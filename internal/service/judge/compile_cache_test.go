@@ -0,0 +1,141 @@
+package judge
+
+import (
+	"context"
+	"path/filepath"
+	"reisen-be/internal/config"
+	"reisen-be/internal/model"
+	"testing"
+	"time"
+)
+
+func newTestCompileCache(t *testing.T, capacity int, ttl time.Duration) (*CompileCache, *FakeSandbox) {
+	t.Helper()
+	languages, err := config.LoadLanguages(filepath.Join(t.TempDir(), "languages.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCompileCache(NewCompiler(languages, nil), capacity, ttl), NewFakeSandbox()
+}
+
+func compileTask(code string) *model.JudgeTask {
+	return &model.JudgeTask{
+		Submission: model.Submission{
+			SubmissionCore: model.SubmissionCore{Lang: "cpp14"},
+			Code:           code,
+		},
+	}
+}
+
+// 等待沙箱中的文件被删除，编译结果在后台删除
+func waitForDeleted(t *testing.T, sb *FakeSandbox, fileId string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := sb.OpenFile(context.Background(), fileId); err != nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("file %s is not deleted", fileId)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func fileExists(sb *FakeSandbox, fileId string) bool {
+	_, err := sb.OpenFile(context.Background(), fileId)
+	return err == nil
+}
+
+func TestCompileCacheEviction(t *testing.T) {
+	tests := []struct {
+		name        string
+		capacity    int
+		codes       []string // 依次编译的代码
+		wantCompile int      // 实际请求沙箱编译的次数
+	}{
+		{"same code compiled once", 2, []string{"a", "a", "a"}, 1},
+		{"distinct codes", 2, []string{"a", "b", "a", "b"}, 2},
+		{"least recently used evicted", 2, []string{"a", "b", "c", "a"}, 4},
+		{"recently used kept", 2, []string{"a", "b", "a", "c", "a"}, 3},
+		{"caching disabled", 0, []string{"a", "a"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, sb := newTestCompileCache(t, tt.capacity, time.Hour)
+			for _, code := range tt.codes {
+				_, _, release, err := cache.Compile(context.Background(), sb, compileTask(code))
+				if err != nil {
+					t.Fatal(err)
+				}
+				release()
+			}
+			if got := len(sb.Requests()); got != tt.wantCompile {
+				t.Fatalf("got %d compilations, want %d", got, tt.wantCompile)
+			}
+		})
+	}
+}
+
+func TestCompileCacheDeletesEvictedFiles(t *testing.T) {
+	cache, sb := newTestCompileCache(t, 1, time.Hour)
+
+	first, _, releaseFirst, err := cache.Compile(context.Background(), sb, compileTask("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, releaseSecond, err := cache.Compile(context.Background(), sb, compileTask("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseSecond()
+
+	// 仍在使用的编译结果被淘汰后保留到释放为止
+	if !fileExists(sb, first) {
+		t.Fatalf("evicted file %s is deleted while in use", first)
+	}
+	releaseFirst()
+	waitForDeleted(t, sb, first)
+}
+
+func TestCompileCacheExpiry(t *testing.T) {
+	cache, sb := newTestCompileCache(t, 4, 10*time.Millisecond)
+
+	fileId, _, release, err := cache.Compile(context.Background(), sb, compileTask("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	time.Sleep(20 * time.Millisecond)
+
+	if _, _, release, err = cache.Compile(context.Background(), sb, compileTask("a")); err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if got := len(sb.Requests()); got != 2 {
+		t.Fatalf("got %d compilations, want 2", got)
+	}
+	waitForDeleted(t, sb, fileId)
+}
+
+func TestCompileCacheKeepsCompileErrors(t *testing.T) {
+	cache, sb := newTestCompileCache(t, 4, time.Hour)
+	sb.RunFunc = func(payload *model.RunRequestPayload) ([]GoJudgeResult, error) {
+		return []GoJudgeResult{{
+			Status:     model.StatusNonzeroExitStatus,
+			ExitStatus: 1,
+			Files:      map[string]string{"stderr": "error"},
+		}}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		_, info, release, err := cache.Compile(context.Background(), sb, compileTask("a"))
+		if err == nil || info == nil || info.Success {
+			t.Fatalf("got info %+v, error %v, want a compile error", info, err)
+		}
+		release()
+	}
+	if got := len(sb.Requests()); got != 1 {
+		t.Fatalf("got %d compilations, want 1", got)
+	}
+}
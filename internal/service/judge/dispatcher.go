@@ -2,32 +2,44 @@ package judge
 
 import (
 	"context"
-	"os"
+	"fmt"
+	"math"
 	"path/filepath"
 	"reisen-be/internal/filesystem"
 	"reisen-be/internal/model"
 	"reisen-be/internal/websocket"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // 每个 Dispatcher 管理一个评测记录从提交评测、编译、运行、判分的全过程
 type Dispatcher struct {
+	sandboxes         *SandboxPool
 	compiler          *Compiler
+	compileCache      *CompileCache
 	runner            *Runner
-	checker           Checker
+	programs          *ProgramCache
 	taskQueue         chan *model.JudgeTask
 	submissionChan    chan *model.Submission
 	workers           int
+	pending           atomic.Int64 // 已提交但尚未产出结果的任务数
+	busy              atomic.Int64 // 正在评测的 worker 数
+	stopped           bool         // 已停止接收任务
+	mu                sync.Mutex
+	metrics           *Metrics
 	problemFilesystem *filesystem.ProblemFilesystem
 	submissionWs      *websocket.SubmissionWs
 }
-func NewDispatcher(workers int, compiler *Compiler, runner *Runner, checker Checker, problemFilesystem *filesystem.ProblemFilesystem, submissionWs *websocket.SubmissionWs) *Dispatcher {
+func NewDispatcher(workers int, sandboxes *SandboxPool, compiler *Compiler, compileCache *CompileCache, runner *Runner, metrics *Metrics, problemFilesystem *filesystem.ProblemFilesystem, submissionWs *websocket.SubmissionWs) *Dispatcher {
 	return &Dispatcher{
+		sandboxes:         sandboxes,
 		compiler:          compiler,
+		compileCache:      compileCache,
 		runner:            runner,
-		checker:           checker,
-		taskQueue:         make(chan *model.JudgeTask, 100),
+		metrics:           metrics,
+		programs:          NewProgramCache(compiler),
+		taskQueue:         make(chan *model.JudgeTask, workers),
 		submissionChan:    make(chan *model.Submission, 100),
 		workers:           workers,
 		problemFilesystem: problemFilesystem,
@@ -42,16 +54,65 @@ func (d *Dispatcher) Start(ctx context.Context) {
 		wg.Add(1)
 		go d.worker(ctx, &wg)
 	}
+	d.compileCache.StartCleaner(ctx, time.Minute)
 
 	go func() {
 		wg.Wait()
+		d.compileCache.Clear()
 		close(d.submissionChan)
 	}()
 }
 
-// 提交评测任务
-func (d *Dispatcher) Submit(task *model.JudgeTask) {
+// 提交评测任务，所有 worker 均忙碌或已停止时返回 false 而不阻塞
+func (d *Dispatcher) Submit(task *model.JudgeTask) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return false
+	}
+	if d.pending.Add(1) > int64(d.workers) {
+		d.pending.Add(-1)
+		return false
+	}
 	d.taskQueue <- task
+	return true
+}
+
+// 停止接收任务，worker 评测完已提交的任务后退出，随后 Results 被关闭
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.stopped {
+		d.stopped = true
+		close(d.taskQueue)
+	}
+}
+
+// 空闲 worker 数，即当前可立即接收的任务数
+func (d *Dispatcher) Idle() int {
+	return max(d.workers-int(d.pending.Load()), 0)
+}
+
+// Dispatcher 的运行状态
+type DispatcherStats struct {
+	Workers int // worker 数
+	Busy    int // 正在评测的 worker 数
+	Pending int // 已提交但尚未产出结果的任务数
+}
+
+func (d *Dispatcher) Stats() DispatcherStats {
+	return DispatcherStats{
+		Workers: d.workers,
+		Busy:    int(d.busy.Load()),
+		Pending: int(d.pending.Load()),
+	}
+}
+
+// 直接评测任务并等待完成：不经过评测队列，也不产出到 Results
+func (d *Dispatcher) Judge(ctx context.Context, task *model.JudgeTask) {
+	d.judgeTask(ctx, task)
 }
 
 // 收集评测结果（Submission）
@@ -65,112 +126,201 @@ func (d *Dispatcher) worker(ctx context.Context, wg *sync.WaitGroup) {
 	// 从评测队列获取评测任务
 	for {
 		select {
-		case task := <-d.taskQueue:
-			d.judgeTask(task)
+		case task, ok := <-d.taskQueue:
+			if !ok {
+				return
+			}
+			start := time.Now()
+			d.busy.Add(1)
+			d.judgeTask(ctx, task)
+			d.busy.Add(-1)
+
+			// 评测被中断时结果不可信，不提交结果，提交仍留在评测队列中
+			if ctx.Err() != nil {
+				d.pending.Add(-1)
+				return
+			}
+			d.metrics.ObserveJudge(task.Verdict, time.Since(start))
 			d.submissionChan <- &task.Submission
+			d.pending.Add(-1)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// 单次评测中各测试点共享的信息
+type judgeContext struct {
+	task       *model.JudgeTask
+	sandbox    Sandbox  // 本次评测使用的沙箱后端
+	root       string   // 题目目录
+	fileId     string   // 选手程序
+	checker    Checker  // 传统题判分器
+	interactor *Program // 交互题交互器
+
+	points   []float64 // 各测试点得分比例
+	judged   []bool    // 各测试点是否已评测
+	progress chan int  // 测试点评测完成通知
+}
+
+// 评测失败时将所有测试点标记为同一结果
+func (d *Dispatcher) failTask(task *model.JudgeTask, verdict model.VerdictId, message *string) {
+	task.Verdict = verdict
+	for i := range task.Testcases {
+		task.Testcases[i].Verdict = verdict
+		task.Testcases[i].Checker = message
+	}
+	for i := range task.Subtasks {
+		task.Subtasks[i].Verdict = verdict
+	}
+	d.broadcast(task)
+}
+
+// 广播评测进度，交互记录仅保存在评测记录中
+func (d *Dispatcher) broadcast(task *model.JudgeTask) {
+	if task.Silent {
+		return
+	}
+	submission := task.Submission
+	submission.Testcases = make(model.TestcaseList, len(task.Testcases))
+	copy(submission.Testcases, task.Testcases)
+	for i := range submission.Testcases {
+		submission.Testcases[i].Transcript = nil
+	}
+	d.submissionWs.Broadcast(task.ID, submission)
+}
+
 // 运行任务并写入运行结果
-func (d *Dispatcher) judgeTask(task *model.JudgeTask) {
+func (d *Dispatcher) judgeTask(ctx context.Context, task *model.JudgeTask) {
+
+	// 同一沙箱后端的运行名额在各评测任务之间轮流分配
+	ctx = WithFairKey(ctx, task.ID)
 
 	task.Verdict = model.VerdictJD
+	jc := &judgeContext{
+		task:   task,
+		root:   d.problemFilesystem.GetProblemPath(task.ProblemID),
+		points: make([]float64, len(task.Config.TestCases)),
+		judged: make([]bool, len(task.Config.TestCases)),
+	}
 
-	// 1. 编译代码
-	fileId, compileInfo, err := d.compiler.Compile(task)
-	task.CompileInfo = compileInfo
+	// 检查测试点执行方式与子任务配置
+	switch task.Config.Execution {
+	case "", model.ExecutionAll, model.ExecutionOrdered, model.ExecutionCancel:
+	default:
+		message := fmt.Sprintf("unknown execution %q", task.Config.Execution)
+		d.failTask(task, model.VerdictUKE, &message)
+		return
+	}
+	plans, err := planSubtasks(task.Config)
+	if err != nil {
+		message := err.Error()
+		d.failTask(task, model.VerdictUKE, &message)
+		return
+	}
+	// 同一任务的编译、运行与判分均在同一沙箱后端进行
+	sandbox, err := d.sandboxes.Acquire()
+	if err != nil {
+		message := err.Error()
+		d.failTask(task, model.VerdictUKE, &message)
+		return
+	}
+	defer d.sandboxes.Release(sandbox)
+	jc.sandbox = sandbox
 
+	// 按编程语言调整时空限制
+	limits, err := d.runner.Limits(task)
 	if err != nil {
-		task.Verdict = model.VerdictCE
-		for i := range task.Testcases {
-			task.Testcases[i].Verdict = model.VerdictCE
-		}
-		d.submissionWs.Broadcast(task.ID, task.Submission)
+		task.CompileInfo = &model.CompileInfo{Success: false, Message: err.Error()}
+		d.failTask(task, model.VerdictCE, nil)
 		return
 	}
-	defer d.compiler.DeleteFile(fileId)
+	task.Limits = limits
 
-	// 2. 运行测试用例
-	var wg sync.WaitGroup
-	testCaseChan := make(chan int, len(task.Config.TestCases)) // 用于通知完成的测试点索引
+	task.Subtasks = make(model.SubtaskList, len(task.Config.Subtasks))
+	for i, subtask := range task.Config.Subtasks {
+		task.Subtasks[i] = model.SubtaskResult{
+			ID:        subtask.ID,
+			Verdict:   model.VerdictPD,
+			FullScore: subtask.Score,
+			Testcases: subtask.TestCases,
+		}
+	}
 
-	// 编译通过，生成评测信息
-	for i, tc := range task.Config.TestCases {
-		wg.Add(1)
-		go func(idx int, testCase model.TestCaseConfig) {
-			defer func() {
-				testCaseChan <- idx
-			}()
-			defer wg.Done()
-
-			testResult := &task.Testcases[idx]
-
-			runResult, err := d.runner.Run(task, fileId, testCase, d.problemFilesystem.GetProblemPath(task.ProblemID))
-			if err != nil {
-				message := err.Error()
-				testResult.Verdict = model.VerdictUKE
-				testResult.Checker = &message
-				return
-			} else {
-				*testResult = *runResult
-				
-				if testResult.Verdict != model.VerdictAC {
-					return
-				}
-			}
+	// 根据题目类型准备判分器或交互器
+	if task.Config.Type == model.ProblemTypeInteractive {
+		if task.Config.Interactor == nil || task.Config.Interactor.Source == "" {
+			message := "interactor is not configured"
+			d.failTask(task, model.VerdictUKE, &message)
+			return
+		}
+		interactor, err := d.programs.Load(ctx, jc.sandbox, jc.root, *task.Config.Interactor)
+		if err != nil {
+			message := err.Error()
+			d.failTask(task, model.VerdictUKE, &message)
+			return
+		}
+		jc.interactor = interactor
+	} else {
+		checker, err := NewChecker(ctx, task.Config, jc.root, jc.sandbox, d.programs)
+		if err != nil {
+			message := err.Error()
+			d.failTask(task, model.VerdictUKE, &message)
+			return
+		}
+		jc.checker = checker
+	}
 
-			// 3. 判分
+	// 1. 编译代码，相同代码的编译结果（包括编译错误）直接复用
+	fileId, compileInfo, release, err := d.compileCache.Compile(ctx, jc.sandbox, task)
+	defer release()
+	task.CompileInfo = compileInfo
 
-			// 提取答案文件
-			expectedOutput, err := os.ReadFile(filepath.Join(d.problemFilesystem.GetProblemPath(task.ProblemID), testCase.OutputFile))
-			if err != nil {
-				message := err.Error()
-				testResult.Verdict = model.VerdictUKE
-				testResult.Checker = &message
-				return
-			}
+	if err != nil {
+		// 没有编译信息说明请求沙箱失败，而非编译错误
+		if compileInfo == nil {
+			message := err.Error()
+			d.failTask(task, model.VerdictUKE, &message)
+			return
+		}
+		d.failTask(task, model.VerdictCE, nil)
+		return
+	}
+	jc.fileId = fileId
 
-			// 检查是否通过
-			passed, message := d.checker.Check(*testResult.Output, string(expectedOutput))
-			if message != "" {
-				// 校验器输出信息
-				testResult.Checker = &message
-			}
-			if passed {
-				testResult.Verdict = model.VerdictAC
-				testResult.Score = &testCase.Score
-			} else {
-				testResult.Verdict = model.VerdictWA
-			}
+	// 2. 运行测试用例
+	jc.progress = make(chan int, len(task.Config.TestCases)) // 用于通知完成的测试点索引
 
-		}(i, tc)
-	}
-	
 	// 启动协程监听测试点完成
 	go func() {
 		lastUpdate := time.Now()
 		updatedCount := 0
 		
-		for range testCaseChan {
+		for range jc.progress {
 			updatedCount ++
 			
 			// 满足以下条件之一时更新评测结果：
 			// 1. 已有 10 个以上测试点状态被更新
 			// 2. 已有  5 秒以上测试点状态未更新
 			if updatedCount >= 10 || time.Since(lastUpdate) > time.Second * 5 {
-				d.submissionWs.Broadcast(task.ID, task.Submission)
+				d.broadcast(task)
 				lastUpdate = time.Now()
 				updatedCount = 0
 			}
 		}
 	}()
 
-	wg.Wait()
-	// 关闭 testCaseChan，通知监听 goroutine 退出
-	close(testCaseChan)
+	if len(plans) > 0 {
+		d.judgeSubtasks(ctx, jc, plans)
+	} else {
+		indices := make([]int, len(task.Config.TestCases))
+		for i := range indices {
+			indices[i] = i
+		}
+		d.runTestcases(ctx, jc, indices, task.Config.Execution)
+	}
+	// 关闭 progress，通知监听 goroutine 退出
+	close(jc.progress)
 
 	// 等待评测完毕，收集结果
 	var maxTimeUsed, maxMemoryUsed, totalScore int
@@ -184,16 +334,10 @@ func (d *Dispatcher) judgeTask(task *model.JudgeTask) {
 		if tr.Memory != nil {
 			maxMemoryUsed = max(*tr.Memory, maxMemoryUsed)
 		}
-		if tr.Score != nil {
+		if tr.Score != nil && len(plans) == 0 {
 			totalScore += *tr.Score
 		}
-		if tr.Input != nil && len(*tr.Input) > 256 {
-			*tr.Input = (*tr.Input)[0:256] + "..."
-		}
-		if tr.Output != nil && len(*tr.Output) > 256 {
-				*tr.Output = (*tr.Output)[0:256] + "..."
-		}
-		if allPassed && tr.Verdict != model.VerdictAC {
+		if allPassed && tr.Verdict != model.VerdictAC && tr.Verdict != model.VerdictSKP {
 			allPassed = false
 			task.Verdict = tr.Verdict
 		}
@@ -204,6 +348,11 @@ func (d *Dispatcher) judgeTask(task *model.JudgeTask) {
 		task.Verdict = model.VerdictAC
 	}
 
+	// 存在子任务时按子任务计分
+	for _, subtask := range task.Subtasks {
+		totalScore += subtask.Score
+	}
+
 	task.TimeUsed = &maxTimeUsed
 	task.MemoryUsed = &maxMemoryUsed
 	task.Score = &totalScore
@@ -214,5 +363,70 @@ func (d *Dispatcher) judgeTask(task *model.JudgeTask) {
 	}
 	
 	// 广播评测结果
-	d.submissionWs.Broadcast(task.ID, task.Submission)
+	d.broadcast(task)
+}
+
+// 评测单个测试点：运行选手程序并判分，返回测试点结果与得分比例
+func (d *Dispatcher) judgeTestcase(ctx context.Context, jc *judgeContext, testCase model.TestCaseConfig) (model.Testcase, float64) {
+	fail := func(err error) (model.Testcase, float64) {
+		message := err.Error()
+		return model.Testcase{
+			ID:      testCase.ID,
+			Verdict: model.VerdictUKE,
+			Checker: &message,
+		}, 0
+	}
+
+	// 交互题：选手程序超限时以超限为准，否则由交互器判定
+	if jc.interactor != nil {
+		testResult, checkResult, err := d.runner.RunInteractive(ctx, jc.task, jc.fileId, jc.interactor, testCase, jc.root)
+		if err != nil {
+			return fail(err)
+		}
+		switch testResult.Verdict {
+		case model.VerdictTLE, model.VerdictMLE, model.VerdictOLE:
+			return *testResult, 0
+		}
+		if checkResult.Verdict == model.VerdictAC && testResult.Verdict != model.VerdictAC {
+			return *testResult, 0
+		}
+		applyCheckResult(testResult, checkResult, testCase)
+		return *testResult, checkResult.Points
+	}
+
+	testResult, outputId, err := d.runner.Run(ctx, jc.sandbox, jc.task, jc.fileId, testCase, jc.root)
+	if err != nil {
+		return fail(err)
+	}
+	if outputId != "" {
+		defer d.compiler.DeleteFile(context.Background(), jc.sandbox, outputId)
+	}
+	if testResult.Verdict != model.VerdictAC {
+		return *testResult, 0
+	}
+
+	// 3. 判分
+	checkResult, err := jc.checker.Check(ctx, &CheckRequest{
+		InputFile:    filepath.Join(jc.root, testCase.InputFile),
+		AnswerFile:   filepath.Join(jc.root, testCase.OutputFile),
+		OutputFileId: outputId,
+	})
+	if err != nil {
+		return fail(err)
+	}
+	applyCheckResult(testResult, checkResult, testCase)
+	return *testResult, checkResult.Points
+}
+
+// 将判分结果写入测试点
+func applyCheckResult(testResult *model.Testcase, checkResult *CheckResult, testCase model.TestCaseConfig) {
+	if checkResult.Message != "" {
+		// 校验器输出信息
+		testResult.Checker = &checkResult.Message
+	}
+	testResult.Verdict = checkResult.Verdict
+	if checkResult.Points > 0 {
+		score := int(math.Round(checkResult.Points * float64(testCase.Score)))
+		testResult.Score = &score
+	}
 }
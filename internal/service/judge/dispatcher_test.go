@@ -0,0 +1,179 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reisen-be/internal/config"
+	"reisen-be/internal/filesystem"
+	"reisen-be/internal/model"
+	"testing"
+)
+
+const testProblemID model.ProblemId = 1
+
+// 题目测试数据：输入与答案。默认沙箱原样输出输入，答案与输入相同的测试点通过
+type testData struct {
+	input, answer string
+}
+
+func newTestDispatcher(t *testing.T, pool *SandboxPool, data []testData) (*Dispatcher, []model.TestCaseConfig) {
+	t.Helper()
+	languages, err := config.LoadLanguages(filepath.Join(t.TempDir(), "languages.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := filesystem.NewProblemFilesystem(t.TempDir())
+	root := problems.GetProblemPath(testProblemID)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := make([]model.TestCaseConfig, len(data))
+	for i, d := range data {
+		testCases[i] = model.TestCaseConfig{
+			ID:         i + 1,
+			InputFile:  filepath.Join("tests", fmt.Sprintf("%d.in", i+1)),
+			OutputFile: filepath.Join("tests", fmt.Sprintf("%d.ans", i+1)),
+			Score:      100 / len(data),
+		}
+		for name, content := range map[string]string{testCases[i].InputFile: d.input, testCases[i].OutputFile: d.answer} {
+			path := filepath.Join(root, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	compiler := NewCompiler(languages, nil)
+	runner := NewRunner(languages, nil)
+	return NewDispatcher(1, pool, compiler, NewCompileCache(compiler, 8, 0), runner, nil, problems, nil), testCases
+}
+
+func newTestJudgeTask(config model.JudgeConfig) *model.JudgeTask {
+	task := &model.JudgeTask{
+		Submission: model.Submission{
+			SubmissionCore: model.SubmissionCore{ProblemID: testProblemID, Lang: "cpp14"},
+			Code:           "int main() {}",
+		},
+		Config: config,
+		Silent: true,
+	}
+	task.Testcases = make(model.TestcaseList, len(config.TestCases))
+	for i := range task.Testcases {
+		task.Testcases[i].ID = config.TestCases[i].ID
+		task.Testcases[i].Verdict = model.VerdictPD
+	}
+	return task
+}
+
+func TestDispatcherJudgeTask(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []testData
+		config       model.JudgeConfig
+		wantVerdict  model.VerdictId
+		wantScore    int
+		wantVerdicts []model.VerdictId // 各测试点结果
+	}{
+		{
+			name:         "all accepted",
+			data:         []testData{{"1 2\n", "1 2\n"}, {"3\n", "3\n"}},
+			wantVerdict:  model.VerdictAC,
+			wantScore:    100,
+			wantVerdicts: []model.VerdictId{model.VerdictAC, model.VerdictAC},
+		},
+		{
+			name:         "wrong answer scores the rest",
+			data:         []testData{{"1 2\n", "1 2\n"}, {"3\n", "4\n"}},
+			wantVerdict:  model.VerdictWA,
+			wantScore:    50,
+			wantVerdicts: []model.VerdictId{model.VerdictAC, model.VerdictWA},
+		},
+		{
+			name:         "ordered execution skips after failure",
+			data:         []testData{{"1\n", "2\n"}, {"3\n", "3\n"}},
+			config:       model.JudgeConfig{Execution: model.ExecutionOrdered},
+			wantVerdict:  model.VerdictWA,
+			wantScore:    0,
+			wantVerdicts: []model.VerdictId{model.VerdictWA, model.VerdictSKP},
+		},
+		{
+			name:         "unknown checker",
+			data:         []testData{{"1\n", "1\n"}},
+			config:       model.JudgeConfig{CheckerType: "nonexistent"},
+			wantVerdict:  model.VerdictUKE,
+			wantVerdicts: []model.VerdictId{model.VerdictUKE},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewSandboxPool()
+			pool.Add(NewFakeSandbox(), 2)
+			d, testCases := newTestDispatcher(t, pool, tt.data)
+			tt.config.TimeLimit, tt.config.MemoryLimit = 1000, 256
+			tt.config.TestCases = testCases
+			task := newTestJudgeTask(tt.config)
+
+			d.judgeTask(context.Background(), task)
+
+			if task.Verdict != tt.wantVerdict {
+				t.Fatalf("got verdict %s, want %s", task.Verdict, tt.wantVerdict)
+			}
+			if task.Score != nil && *task.Score != tt.wantScore {
+				t.Fatalf("got score %d, want %d", *task.Score, tt.wantScore)
+			}
+			for i, want := range tt.wantVerdicts {
+				if got := task.Testcases[i].Verdict; got != want {
+					t.Fatalf("testcase %d: got %s, want %s", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatcherJudgeTaskBackend(t *testing.T) {
+	tests := []struct {
+		name string
+		down map[string]bool
+		held int // 评测前已被占用的后端数，按添加顺序分配
+		want string
+	}{
+		{"least loaded backend used", nil, 1, "b"},
+		{"unhealthy backend skipped", map[string]bool{"a": true}, 0, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backends := map[string]*poolTestSandbox{}
+			pool := NewSandboxPool()
+			for _, name := range []string{"a", "b"} {
+				backends[name] = &poolTestSandbox{FakeSandbox: NewFakeSandbox(), name: name, down: tt.down[name]}
+				pool.Add(backends[name], 2)
+			}
+			pool.checkHealth(context.Background())
+			for range tt.held {
+				if _, err := pool.Acquire(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d, testCases := newTestDispatcher(t, pool, []testData{{"1\n", "1\n"}})
+			task := newTestJudgeTask(model.JudgeConfig{TimeLimit: 1000, MemoryLimit: 256, TestCases: testCases})
+			d.judgeTask(context.Background(), task)
+
+			if task.Verdict != model.VerdictAC {
+				t.Fatalf("got verdict %s, want %s", task.Verdict, model.VerdictAC)
+			}
+			for name, backend := range backends {
+				used := len(backend.Requests()) > 0
+				if used != (name == tt.want) {
+					t.Fatalf("backend %s used: %v, want backend %s", name, used, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"reisen-be/internal/model"
+	"strings"
+	"sync"
+)
+
+// 进程内的沙箱实现，不实际运行程序，供没有 go-judge 的环境使用。
+// 默认行为：每条命令均正常退出，stdout 回显 stdin，copyOutCached 的文件内容取自 copyIn。
+type FakeSandbox struct {
+	// 自定义运行行为，为空时使用默认行为
+	RunFunc func(payload *model.RunRequestPayload) ([]GoJudgeResult, error)
+
+	files    map[string]string
+	requests []model.RunRequestPayload
+	nextId   int
+	mu       sync.Mutex
+}
+
+func NewFakeSandbox() *FakeSandbox {
+	return &FakeSandbox{
+		files: make(map[string]string),
+	}
+}
+
+func (s *FakeSandbox) Name() string {
+	return "fake"
+}
+
+func (s *FakeSandbox) Run(ctx context.Context, payload *model.RunRequestPayload) ([]GoJudgeResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, *payload)
+	s.mu.Unlock()
+
+	if s.RunFunc != nil {
+		return s.RunFunc(payload)
+	}
+
+	results := make([]GoJudgeResult, len(payload.Cmd))
+	for i, cmd := range payload.Cmd {
+		stdin := ""
+		if len(cmd.Files) > 0 {
+			if file, ok := cmd.Files[0].(map[string]any); ok {
+				stdin = s.readFile(file)
+			}
+		}
+
+		result := GoJudgeResult{
+			Status:  model.StatusAccepted,
+			Files:   map[string]string{},
+			FileIds: map[string]string{},
+		}
+		for _, name := range cmd.CopyOut {
+			switch name {
+			case "stdout":
+				result.Files[name] = stdin
+			case "stderr":
+				result.Files[name] = ""
+			default:
+				result.Files[name] = s.copyInContent(cmd, name)
+			}
+		}
+
+		for _, name := range cmd.CopyOutCached {
+			// 以 ? 结尾的文件不存在时跳过
+			optional := strings.HasSuffix(name, "?")
+			name = strings.TrimSuffix(name, "?")
+			content := stdin
+			if name != "stdout" {
+				if _, ok := cmd.CopyIn[name]; !ok && optional {
+					continue
+				}
+				content = s.copyInContent(cmd, name)
+			}
+			s.mu.Lock()
+			s.nextId++
+			fileId := fmt.Sprintf("fake-%d", s.nextId)
+			s.files[fileId] = content
+			s.mu.Unlock()
+			result.FileIds[name] = fileId
+		}
+
+		results[i] = result
+	}
+	return results, nil
+}
+
+func (s *FakeSandbox) DeleteFile(ctx context.Context, fileId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[fileId]; !ok {
+		return fmt.Errorf("failed to delete file")
+	}
+	delete(s.files, fileId)
+	return nil
+}
+
+func (s *FakeSandbox) OpenFile(ctx context.Context, fileId string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, ok := s.files[fileId]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found", fileId)
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func (s *FakeSandbox) Ping(ctx context.Context) error {
+	return nil
+}
+
+// 已收到的运行请求
+func (s *FakeSandbox) Requests() []model.RunRequestPayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]model.RunRequestPayload(nil), s.requests...)
+}
+
+// 读取 content / src / fileId 形式的文件内容
+func (s *FakeSandbox) readFile(file map[string]any) string {
+	if content, ok := file["content"].(string); ok {
+		return content
+	}
+	if src, ok := file["src"].(string); ok {
+		data, _ := os.ReadFile(src)
+		return string(data)
+	}
+	if fileId, ok := file["fileId"].(string); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.files[fileId]
+	}
+	return ""
+}
+
+func (s *FakeSandbox) copyInContent(cmd model.Cmd, name string) string {
+	file, ok := cmd.CopyIn[name].(map[string]any)
+	if !ok {
+		return ""
+	}
+	return s.readFile(file)
+}
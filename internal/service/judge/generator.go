@@ -0,0 +1,76 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"reisen-be/internal/model"
+	"strings"
+)
+
+// 生成器输出（测试数据）的长度上限
+const generatorOutputLimit = 256 << 20
+
+// 测试数据生成器：按参数将数据输出到标准输出
+type Generator struct {
+	sandbox Sandbox
+	program *Program
+}
+
+// 编译题目目录下的生成器，root 为题目目录
+func NewGenerator(ctx context.Context, sb Sandbox, root string, program model.ProgramConfig, programs *ProgramCache) (*Generator, error) {
+	compiled, err := programs.Load(ctx, sb, root, program)
+	if err != nil {
+		return nil, err
+	}
+	return &Generator{sandbox: sb, program: compiled}, nil
+}
+
+// 以给定参数运行生成器，环境变量 SEED 为确定的随机种子。
+// 输出缓存在沙箱中，返回其 fileId 与用时(ms)，由调用方删除
+func (g *Generator) Generate(ctx context.Context, args []string, seed uint64) (string, int, error) {
+	results, err := g.program.Run(ctx, func(fileId string) *model.RunRequestPayload {
+		return generatorPayload(fileId, args, seed)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	result := &results[0]
+	outputId := result.FileIds["stdout"]
+	if result.Status != model.StatusAccepted {
+		if outputId != "" {
+			g.sandbox.DeleteFile(context.Background(), outputId)
+		}
+		message := strings.TrimSpace(result.Files["stderr"])
+		if result.Status == model.StatusNonzeroExitStatus {
+			return "", 0, fmt.Errorf("generator exited with code %d. %s", result.ExitStatus, message)
+		}
+		return "", 0, fmt.Errorf("generator failed: %s. %s", result.Status, message)
+	}
+	return outputId, int(result.Time / 1_000_000), nil
+}
+
+func generatorPayload(fileId string, args []string, seed uint64) *model.RunRequestPayload {
+	return &model.RunRequestPayload{
+		Cmd: []model.Cmd{
+			{
+				Args:        append([]string{"./generator"}, args...),
+				Env:         []string{"PATH=/usr/bin:/bin", fmt.Sprintf("SEED=%d", seed)},
+				CPULimit:    10_000_000_000,    // 10s
+				ClockLimit:  20_000_000_000,    // 20s
+				MemoryLimit: 512 * 1024 * 1024, // 512MB
+				ProcLimit:   50,
+				Files: []any{
+					map[string]any{"content": ""},
+					map[string]any{"name": "stdout", "max": generatorOutputLimit},
+					map[string]any{"name": "stderr", "max": stderrLimit},
+				},
+				CopyIn: map[string]any{
+					"generator": map[string]any{"fileId": fileId},
+				},
+				CopyOut:       []string{"stderr"},
+				CopyOutCached: []string{"stdout"},
+			},
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package judge
+
+import (
+	"fmt"
+	"io"
+	"reisen-be/internal/model"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 评测耗时与编译耗时的直方图分桶(秒)
+var (
+	judgeBuckets   = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+	compileBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20}
+)
+
+// 直方图，与 Prometheus 的 histogram 语义相同
+type histogram struct {
+	buckets []float64
+	counts  []uint64 // 各分桶（不累计）的观测数，最后一项为 +Inf
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// 评测过程的统计数据，由 Dispatcher、Compiler 与 Runner 记录。
+// 方法对 nil 接收者安全，不需要统计时可传入 nil
+type Metrics struct {
+	verdicts     map[model.VerdictId]uint64 // 各结果的评测任务数
+	judgeSeconds *histogram                 // 评测任务总耗时
+	compile      map[model.CodeLangId]*histogram
+	runStatus    map[string]uint64 // 选手程序各运行状态的次数
+	mu           sync.Mutex
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		verdicts:     make(map[model.VerdictId]uint64),
+		judgeSeconds: newHistogram(judgeBuckets),
+		compile:      make(map[model.CodeLangId]*histogram),
+		runStatus:    make(map[string]uint64),
+	}
+}
+
+// 记录一次评测任务的结果与耗时
+func (m *Metrics) ObserveJudge(verdict model.VerdictId, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.verdicts[verdict]++
+	m.judgeSeconds.observe(duration.Seconds())
+}
+
+// 记录一次编译的耗时
+func (m *Metrics) ObserveCompile(lang model.CodeLangId, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.compile[lang]
+	if !ok {
+		h = newHistogram(compileBuckets)
+		m.compile[lang] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// 记录一次选手程序运行的沙箱状态
+func (m *Metrics) ObserveRun(status string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.runStatus[status]++
+}
+
+// 各结果的评测任务数
+func (m *Metrics) Verdicts() map[model.VerdictId]uint64 {
+	verdicts := map[model.VerdictId]uint64{}
+	if m == nil {
+		return verdicts
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for verdict, count := range m.verdicts {
+		verdicts[verdict] = count
+	}
+	return verdicts
+}
+
+// 以 Prometheus 文本格式输出
+func (m *Metrics) WritePrometheus(w io.Writer) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP reisen_judge_verdicts_total Judged submissions by verdict.")
+	fmt.Fprintln(w, "# TYPE reisen_judge_verdicts_total counter")
+	for _, verdict := range sortedKeys(m.verdicts) {
+		fmt.Fprintf(w, "reisen_judge_verdicts_total{verdict=%q} %d\n", verdict, m.verdicts[verdict])
+	}
+
+	fmt.Fprintln(w, "# HELP reisen_judge_duration_seconds Time spent judging a submission.")
+	fmt.Fprintln(w, "# TYPE reisen_judge_duration_seconds histogram")
+	writeHistogram(w, "reisen_judge_duration_seconds", "", m.judgeSeconds)
+
+	fmt.Fprintln(w, "# HELP reisen_judge_compile_duration_seconds Time spent compiling by language.")
+	fmt.Fprintln(w, "# TYPE reisen_judge_compile_duration_seconds histogram")
+	for _, lang := range sortedKeys(m.compile) {
+		writeHistogram(w, "reisen_judge_compile_duration_seconds", fmt.Sprintf("lang=%q", lang), m.compile[lang])
+	}
+
+	fmt.Fprintln(w, "# HELP reisen_judge_run_status_total Sandbox status of contestant program runs.")
+	fmt.Fprintln(w, "# TYPE reisen_judge_run_status_total counter")
+	for _, status := range sortedKeys(m.runStatus) {
+		fmt.Fprintf(w, "reisen_judge_run_status_total{status=%q} %d\n", status, m.runStatus[status])
+	}
+}
+
+func writeHistogram(w io.Writer, name, labels string, h *histogram) {
+	prefix := labels
+	if prefix != "" {
+		prefix += ","
+	}
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%sle=\"%g\"} %d\n", name, prefix, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return strings.Compare(string(keys[i]), string(keys[j])) < 0
+	})
+	return keys
+}
@@ -0,0 +1,190 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reisen-be/internal/model"
+	"sync"
+	"time"
+)
+
+// 题目附带程序未指定语言时使用的默认语言
+const defaultProgramLang model.CodeLangId = "cpp14"
+
+// 编译结果的有效期，过期后重新编译，避免长期使用沙箱中可能已丢失的文件
+const programTTL = time.Hour
+
+// 已编译的题目附带程序
+type programEntry struct {
+	sandbox  Sandbox
+	modTime  time.Time
+	size     int64
+	fileId   string
+	err      error
+	ready    chan struct{} // 编译完成后关闭
+	expireAt time.Time
+}
+
+// 缓存判分器等题目附带程序在各沙箱后端的编译结果，源文件变化或超过有效期后重新编译
+type ProgramCache struct {
+	compiler *Compiler
+	entries  map[string]*programEntry
+	mu       sync.Mutex
+}
+
+func NewProgramCache(compiler *Compiler) *ProgramCache {
+	return &ProgramCache{
+		compiler: compiler,
+		entries:  make(map[string]*programEntry),
+	}
+}
+
+// 获取程序在指定沙箱中编译后的 fileId，root 为题目目录
+func (p *ProgramCache) Get(ctx context.Context, sb Sandbox, root string, program model.ProgramConfig) (string, error) {
+	lang := program.Lang
+	if lang == "" {
+		lang = defaultProgramLang
+	}
+	sourcePath := filepath.Join(root, program.Source)
+
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return "", err
+	}
+
+	key := sb.Name() + ":" + string(lang) + ":" + sourcePath
+
+	p.mu.Lock()
+	if entry, ok := p.entries[key]; ok {
+		if entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() && time.Now().Before(entry.expireAt) {
+			p.mu.Unlock()
+			// 等待其他评测任务完成同一程序的编译
+			select {
+			case <-entry.ready:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			return entry.fileId, entry.err
+		}
+		// 源文件已更新或结果已过期，旧的编译完成后删除其结果
+		delete(p.entries, key)
+		go func(old *programEntry) {
+			<-old.ready
+			if old.fileId != "" {
+				p.compiler.DeleteFile(context.Background(), old.sandbox, old.fileId)
+			}
+		}(entry)
+	}
+	entry := &programEntry{
+		sandbox:  sb,
+		modTime:  info.ModTime(),
+		size:     info.Size(),
+		ready:    make(chan struct{}),
+		expireAt: time.Now().Add(programTTL),
+	}
+	p.entries[key] = entry
+	p.mu.Unlock()
+
+	var cacheable bool
+	entry.fileId, cacheable, entry.err = p.compile(ctx, sb, lang, sourcePath, program.Source)
+
+	p.mu.Lock()
+	// 沙箱请求失败不缓存，下次重试
+	if !cacheable && p.entries[key] == entry {
+		delete(p.entries, key)
+	}
+	close(entry.ready)
+	p.mu.Unlock()
+
+	return entry.fileId, entry.err
+}
+
+// 将沙箱中的编译结果移出缓存，下次获取时重新编译
+func (p *ProgramCache) invalidate(sb Sandbox, fileId string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, entry := range p.entries {
+		select {
+		case <-entry.ready:
+		default:
+			continue
+		}
+		if entry.fileId == fileId && entry.sandbox.Name() == sb.Name() {
+			delete(p.entries, key)
+			go p.compiler.DeleteFile(context.Background(), sb, fileId)
+		}
+	}
+}
+
+// 编译源文件，沙箱请求失败（而非编译错误）时结果不可缓存
+func (p *ProgramCache) compile(ctx context.Context, sb Sandbox, lang model.CodeLangId, sourcePath, source string) (string, bool, error) {
+	code, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return "", false, err
+	}
+
+	// 题目目录下的 testlib.h 一并复制进沙箱
+	extraFiles := map[string]any{}
+	testlibPath := filepath.Join(filepath.Dir(sourcePath), "testlib.h")
+	if _, err := os.Stat(testlibPath); err == nil {
+		extraFiles["testlib.h"] = map[string]any{"src": testlibPath}
+	}
+
+	fileId, compileInfo, err := p.compiler.CompileSource(ctx, sb, lang, string(code), extraFiles)
+	if err != nil {
+		if compileInfo == nil {
+			return "", false, err
+		}
+		return "", true, fmt.Errorf("failed to compile %s: %s", source, compileInfo.Message)
+	}
+	return fileId, true, nil
+}
+
+// 在沙箱中编译好的题目附带程序
+type Program struct {
+	programs *ProgramCache
+	sandbox  Sandbox
+	root     string
+	config   model.ProgramConfig
+}
+
+// 编译题目目录下的程序，root 为题目目录
+func (p *ProgramCache) Load(ctx context.Context, sb Sandbox, root string, program model.ProgramConfig) (*Program, error) {
+	if _, err := p.Get(ctx, sb, root, program); err != nil {
+		return nil, err
+	}
+	return &Program{programs: p, sandbox: sb, root: root, config: program}, nil
+}
+
+// 以 build 构造的请求运行程序，build 的参数为程序的 fileId。
+// 请求失败或报告文件错误时（如沙箱重启后缓存的文件丢失），重新编译并重试一次
+func (p *Program) Run(ctx context.Context, build func(fileId string) *model.RunRequestPayload) ([]GoJudgeResult, error) {
+	for retried := false; ; retried = true {
+		fileId, err := p.programs.Get(ctx, p.sandbox, p.root, p.config)
+		if err != nil {
+			return nil, err
+		}
+		results, err := p.sandbox.Run(ctx, build(fileId))
+		if retried || ctx.Err() != nil || (err == nil && !hasFileError(results)) {
+			return results, err
+		}
+		for _, result := range results {
+			for _, id := range result.FileIds {
+				p.sandbox.DeleteFile(context.Background(), id)
+			}
+		}
+		p.programs.invalidate(p.sandbox, fileId)
+	}
+}
+
+func hasFileError(results []GoJudgeResult) bool {
+	for _, result := range results {
+		if result.Status == model.StatusFileError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reisen-be/internal/config"
+	"reisen-be/internal/model"
+	"testing"
+)
+
+// 模拟沙箱重启：编译结果在 lost 中的文件已丢失，运行时报告文件错误
+func restartedSandbox(lost map[string]bool) *FakeSandbox {
+	sb := NewFakeSandbox()
+	compiled := 0
+	sb.RunFunc = func(payload *model.RunRequestPayload) ([]GoJudgeResult, error) {
+		cmd := payload.Cmd[0]
+		if len(cmd.CopyOutCached) > 0 {
+			compiled++
+			return []GoJudgeResult{{
+				Status:  model.StatusAccepted,
+				FileIds: map[string]string{cmd.CopyOutCached[0]: fmt.Sprintf("program-%d", compiled)},
+			}}, nil
+		}
+		file, _ := cmd.CopyIn["program"].(map[string]any)
+		if fileId, _ := file["fileId"].(string); lost[fileId] {
+			return []GoJudgeResult{{Status: model.StatusFileError}}, nil
+		}
+		return []GoJudgeResult{{Status: model.StatusAccepted}}, nil
+	}
+	return sb
+}
+
+func TestProgramRunRecompilesLostFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		lost        map[string]bool
+		wantStatus  string
+		wantCompile int
+	}{
+		{"cached program reused", nil, model.StatusAccepted, 1},
+		{"lost program recompiled", map[string]bool{"program-1": true}, model.StatusAccepted, 2},
+		{"retried only once", map[string]bool{"program-1": true, "program-2": true}, model.StatusFileError, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			languages, err := config.LoadLanguages(filepath.Join(t.TempDir(), "languages.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			root := t.TempDir()
+			if err := os.WriteFile(filepath.Join(root, "check.cpp"), []byte("int main() {}"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			sb := restartedSandbox(tt.lost)
+			programs := NewProgramCache(NewCompiler(languages, nil))
+
+			program, err := programs.Load(context.Background(), sb, root, model.ProgramConfig{Source: "check.cpp"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := program.Run(context.Background(), func(fileId string) *model.RunRequestPayload {
+				return &model.RunRequestPayload{Cmd: []model.Cmd{{
+					Args:   []string{"./program"},
+					CopyIn: map[string]any{"program": map[string]any{"fileId": fileId}},
+				}}}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results[0].Status != tt.wantStatus {
+				t.Fatalf("got status %s, want %s", results[0].Status, tt.wantStatus)
+			}
+
+			compiled := 0
+			for _, request := range sb.Requests() {
+				if len(request.Cmd[0].CopyOutCached) > 0 {
+					compiled++
+				}
+			}
+			if compiled != tt.wantCompile {
+				t.Fatalf("got %d compilations, want %d", compiled, tt.wantCompile)
+			}
+		})
+	}
+}
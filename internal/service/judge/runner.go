@@ -1,16 +1,15 @@
 package judge
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
-	"net/http"
+	"math"
 	"os"
 	"path/filepath"
+	"reisen-be/internal/config"
 	"reisen-be/internal/model"
-	"sync"
-	"time"
+	"strings"
 )
 
 type GoJudgeResult struct {
@@ -32,99 +31,454 @@ type GoJudgeFileError struct {
 }
 
 type Runner struct {
-	client *http.Client
-	mu     sync.Mutex
+	languages *config.LanguageRegistry
+	metrics   *Metrics
 }
 
-func NewRunner() *Runner {
-	return &Runner{
-		client: &http.Client{Timeout: 30 * time.Second},
-	}
+func NewRunner(languages *config.LanguageRegistry, metrics *Metrics) *Runner {
+	return &Runner{languages: languages, metrics: metrics}
 }
 
-// 运行得到测试点结果
-func (r *Runner) Run(task *model.JudgeTask, fileId string, testCase model.TestCaseConfig, root string) (*model.Testcase, error) {
-	langConfig := getLangConfig(task.Lang)
+// 获取提交所用编程语言下的时空限制
+func (r *Runner) Limits(task *model.JudgeTask) (*model.JudgeLimits, error) {
+	langConfig := r.languages.Get(task.Lang)
 	if langConfig == nil {
 		return nil, fmt.Errorf("unsupported language: %s", task.Lang)
 	}
+	limits := languageLimits(langConfig, task.Config)
+	return &limits, nil
+}
+
+const (
+	previewSize         = 256 // 输入输出摘要的长度
+	defaultProcessLimit = 50
+	defaultOutputExtra  = 1 << 20 // 默认输出限制在答案文件大小的 2 倍之外额外允许的字节数
+	stderrLimit         = 10240
+	customOutputLimit   = 64 << 10 // 自测时程序输出的长度上限
+	solutionOutputLimit = 64 << 20 // 标准程序生成答案文件的默认长度上限
+)
+
+// 按编程语言的倍率与额外限制调整题目的时空限制，并补全未配置的限制
+func languageLimits(langConfig *model.LanguageConfig, config model.JudgeConfig) model.JudgeLimits {
+	limits := model.JudgeLimits{
+		TimeLimit:         config.TimeLimit,
+		MemoryLimit:       config.MemoryLimit,
+		ActualTimeLimit:   int(math.Ceil(float64(config.TimeLimit)*langConfig.Ratio)) + langConfig.TimeExtra,
+		ActualMemoryLimit: int(math.Ceil(float64(config.MemoryLimit)*langConfig.MemoryRatio)) + langConfig.MemoryOverhead,
+		ClockLimit:        config.ClockLimit,
+		StackLimit:        config.StackLimit,
+		ProcessLimit:      config.ProcessLimit,
+	}
+	if limits.ClockLimit <= 0 {
+		limits.ClockLimit = limits.ActualTimeLimit*2 + 1000
+	}
+	if limits.StackLimit <= 0 {
+		limits.StackLimit = limits.ActualMemoryLimit
+	}
+	if limits.ProcessLimit <= 0 {
+		limits.ProcessLimit = defaultProcessLimit
+	}
+	return limits
+}
+
+// 测试点的输出限制(字节)，未配置时按答案文件大小确定
+func outputLimit(config model.JudgeConfig, answerPath string) (int64, error) {
+	if config.OutputLimit > 0 {
+		return int64(config.OutputLimit) * 1024, nil
+	}
+	info, err := os.Stat(answerPath)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size()*2 + defaultOutputExtra, nil
+}
+
+// 选手程序的运行命令，未设置输入输出
+func contestantCmd(langConfig *model.LanguageConfig, limits model.JudgeLimits, fileId string) model.Cmd {
+	return model.Cmd{
+		Args:        langConfig.RunArgs,
+		Env:         langConfig.RunEnv,
+		CPULimit:    uint64(limits.ActualTimeLimit) * 1_000_000,
+		ClockLimit:  uint64(limits.ClockLimit) * 1_000_000,
+		MemoryLimit: uint64(limits.ActualMemoryLimit) * 1024 * 1024,
+		StackLimit:  uint64(limits.StackLimit) * 1024 * 1024,
+		ProcLimit:   uint64(limits.ProcessLimit),
+		CopyIn: map[string]any{
+			langConfig.OutputFile: map[string]any{
+				"fileId": fileId,
+			},
+		},
+	}
+}
+
+// 运行得到测试点结果，选手输出缓存在沙箱中，返回其 fileId，由调用方删除
+func (r *Runner) Run(ctx context.Context, sb Sandbox, task *model.JudgeTask, fileId string, testCase model.TestCaseConfig, root string) (*model.Testcase, string, error) {
+	langConfig := r.languages.Get(task.Lang)
+	if langConfig == nil {
+		return nil, "", fmt.Errorf("unsupported language: %s", task.Lang)
+	}
+	limits := languageLimits(langConfig, task.Config)
+
+	stdin, err := readPreview(filepath.Join(root, testCase.InputFile))
+	if err != nil {
+		return nil, "", err
+	}
 
-	inputFile, err := os.Open(filepath.Join(root, testCase.InputFile))
+	maxOutput, err := outputLimit(task.Config, filepath.Join(root, testCase.OutputFile))
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	defer inputFile.Close()
 
-	// 创建一个最多容纳 256 字节的缓冲区
-	buffer := make([]byte, 256)
+	inputPath := filepath.Join(root, testCase.InputFile)
+	cmd := contestantCmd(langConfig, limits, fileId)
+	cmd.Files = []any{
+		map[string]any{"src": inputPath},                     // input_file -> stdin
+		map[string]any{"name": "stdout", "max": maxOutput},   // stdout -> stdout
+		map[string]any{"name": "stderr", "max": stderrLimit}, // stderr -> stderr
+	}
+	cmd.CopyOut = []string{"stderr"}
+	cmd.CopyOutCached = []string{"stdout"}
 
-	// 从输入文件中读取数据到缓冲区
-	inputLen, err := inputFile.Read(buffer)
-	if err != nil && err != io.EOF {
-		return nil, err
+	// 文件输入输出：测试数据以指定文件名复制进沙箱，并取回指定的输出文件
+	if err := checkFileNames(task.Config, langConfig); err != nil {
+		return nil, "", err
+	}
+	outputName := "stdout"
+	if name := task.Config.InputFileName; name != "" {
+		cmd.Files[0] = map[string]any{"content": ""}
+		cmd.CopyIn[name] = map[string]any{"src": inputPath}
+	}
+	if name := task.Config.OutputFileName; name != "" {
+		// 输出文件不存在时不视为沙箱错误，由下方判为答案错误
+		outputName = name
+		cmd.CopyOutCached = []string{name + "?"}
+		cmd.CopyOutMax = uint64(maxOutput)
 	}
-	stdin := string(buffer[:inputLen])
 
 	payload := model.RunRequestPayload{
+		Cmd: []model.Cmd{cmd},
+	}
+
+	results, err := sb.Run(ctx, &payload)
+	if err != nil {
+		return nil, "", err
+	}
+	result := results[0]
+	r.metrics.ObserveRun(result.Status)
+	stderr := ""
+
+	if message, ok := result.Files["stderr"]; ok {
+		stderr = message
+	}
+
+	// 选手输出仅保留开头部分用于展示
+	outputId := result.FileIds[outputName]
+	stdout := ""
+	if outputId != "" {
+		if stdout, err = readSandboxPreview(ctx, sb, outputId); err != nil {
+			sb.DeleteFile(context.Background(), outputId)
+			return nil, "", err
+		}
+	}
+
+	timeUsed := int(result.Time / 1_000_000)
+	memoryUsed := int(result.Memory / 1024)
+
+	testResult := &model.Testcase{
+		ID:      testCase.ID,
+		Time:    &timeUsed,
+		Memory:  &memoryUsed,
+		Input:   &stdin,
+		Output:  &stdout,
+		Checker: &stderr,
+	}
+
+	applyStatus(&result, testResult)
+
+	if testResult.Verdict == model.VerdictAC && outputId == "" && task.Config.OutputFileName != "" {
+		message := fmt.Sprintf("Output file %s not found", outputName)
+		testResult.Verdict = model.VerdictWA
+		testResult.Checker = &message
+	}
+
+	return testResult, outputId, nil
+}
+
+// 以给定输入运行程序（自测），返回运行结果及程序输出与标准错误输出
+func (r *Runner) RunCustom(ctx context.Context, sb Sandbox, task *model.JudgeTask, fileId, input string) (*model.Testcase, string, string, error) {
+	langConfig := r.languages.Get(task.Lang)
+	if langConfig == nil {
+		return nil, "", "", fmt.Errorf("unsupported language: %s", task.Lang)
+	}
+	if err := checkFileNames(task.Config, langConfig); err != nil {
+		return nil, "", "", err
+	}
+	limits := languageLimits(langConfig, task.Config)
+
+	cmd := contestantCmd(langConfig, limits, fileId)
+	cmd.Files = []any{
+		map[string]any{"content": input},
+		map[string]any{"name": "stdout", "max": customOutputLimit},
+		map[string]any{"name": "stderr", "max": stderrLimit},
+	}
+	cmd.CopyOut = []string{"stdout", "stderr"}
+
+	outputName := "stdout"
+	if name := task.Config.InputFileName; name != "" {
+		cmd.Files[0] = map[string]any{"content": ""}
+		cmd.CopyIn[name] = map[string]any{"content": input}
+	}
+	if name := task.Config.OutputFileName; name != "" {
+		outputName = name
+		cmd.CopyOut = []string{name + "?", "stderr"}
+		cmd.CopyOutMax = customOutputLimit
+	}
+
+	results, err := sb.Run(ctx, &model.RunRequestPayload{Cmd: []model.Cmd{cmd}})
+	if err != nil {
+		return nil, "", "", err
+	}
+	result := results[0]
+
+	timeUsed := int(result.Time / 1_000_000)
+	memoryUsed := int(result.Memory / 1024)
+	testResult := &model.Testcase{
+		ID:     1,
+		Time:   &timeUsed,
+		Memory: &memoryUsed,
+	}
+	applyStatus(&result, testResult)
+
+	return testResult, result.Files[outputName], result.Files["stderr"], nil
+}
+
+// 运行标准程序生成答案，程序输出缓存在沙箱中，返回其 fileId，由调用方删除
+func (r *Runner) RunSolution(ctx context.Context, sb Sandbox, task *model.JudgeTask, fileId, inputPath string) (*model.Testcase, string, error) {
+	langConfig := r.languages.Get(task.Lang)
+	if langConfig == nil {
+		return nil, "", fmt.Errorf("unsupported language: %s", task.Lang)
+	}
+	if err := checkFileNames(task.Config, langConfig); err != nil {
+		return nil, "", err
+	}
+	limits := languageLimits(langConfig, task.Config)
+
+	maxOutput := int64(solutionOutputLimit)
+	if task.Config.OutputLimit > 0 {
+		maxOutput = int64(task.Config.OutputLimit) * 1024
+	}
+
+	cmd := contestantCmd(langConfig, limits, fileId)
+	cmd.Files = []any{
+		map[string]any{"src": inputPath},
+		map[string]any{"name": "stdout", "max": maxOutput},
+		map[string]any{"name": "stderr", "max": stderrLimit},
+	}
+	cmd.CopyOut = []string{"stderr"}
+	cmd.CopyOutCached = []string{"stdout"}
+
+	outputName := "stdout"
+	if name := task.Config.InputFileName; name != "" {
+		cmd.Files[0] = map[string]any{"content": ""}
+		cmd.CopyIn[name] = map[string]any{"src": inputPath}
+	}
+	if name := task.Config.OutputFileName; name != "" {
+		outputName = name
+		cmd.CopyOutCached = []string{name + "?"}
+		cmd.CopyOutMax = uint64(maxOutput)
+	}
+
+	results, err := sb.Run(ctx, &model.RunRequestPayload{Cmd: []model.Cmd{cmd}})
+	if err != nil {
+		return nil, "", err
+	}
+	result := results[0]
+
+	timeUsed := int(result.Time / 1_000_000)
+	memoryUsed := int(result.Memory / 1024)
+	testResult := &model.Testcase{
+		ID:     1,
+		Time:   &timeUsed,
+		Memory: &memoryUsed,
+	}
+	applyStatus(&result, testResult)
+
+	outputId := result.FileIds[outputName]
+	if testResult.Verdict == model.VerdictAC && outputId == "" {
+		message := fmt.Sprintf("Output file %s not found", outputName)
+		testResult.Verdict = model.VerdictRE
+		testResult.Checker = &message
+	}
+	if testResult.Checker == nil && result.Files["stderr"] != "" {
+		stderr := result.Files["stderr"]
+		testResult.Checker = &stderr
+	}
+	return testResult, outputId, nil
+}
+
+// 检查文件输入输出的文件名，不能包含路径或与程序文件重名
+func checkFileNames(config model.JudgeConfig, langConfig *model.LanguageConfig) error {
+	for _, name := range []string{config.InputFileName, config.OutputFileName} {
+		if name == "" {
+			continue
+		}
+		if name != filepath.Base(name) || name == "." || name == ".." || strings.HasSuffix(name, "?") {
+			return fmt.Errorf("invalid file name %q", name)
+		}
+		if name == langConfig.OutputFile || name == langConfig.SourceFile {
+			return fmt.Errorf("file name %q conflicts with the program", name)
+		}
+	}
+	if config.InputFileName != "" && config.InputFileName == config.OutputFileName {
+		return fmt.Errorf("input and output file are both %q", config.InputFileName)
+	}
+	return nil
+}
+
+// 运行交互题测试点：选手程序与交互器通过管道相连，返回选手程序的运行结果与交互器的判定
+func (r *Runner) RunInteractive(ctx context.Context, task *model.JudgeTask, fileId string, interactor *Program, testCase model.TestCaseConfig, root string) (*model.Testcase, *CheckResult, error) {
+	langConfig := r.languages.Get(task.Lang)
+	if langConfig == nil {
+		return nil, nil, fmt.Errorf("unsupported language: %s", task.Lang)
+	}
+	limits := languageLimits(langConfig, task.Config)
+
+	stdin, err := readPreview(filepath.Join(root, testCase.InputFile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmd := contestantCmd(langConfig, limits, fileId)
+	cmd.Files = []any{
+		nil, // stdin <- 交互器 stdout
+		nil, // stdout -> 交互器 stdin
+		map[string]any{"name": "stderr", "max": stderrLimit},
+	}
+	cmd.CopyOut = []string{"stderr"}
+
+	payload := &model.RunRequestPayload{
 		Cmd: []model.Cmd{
+			cmd,
 			{
-				Args:        langConfig.RunArgs,
-				Env:         langConfig.RunEnv,
-				CPULimit:    uint64(task.Config.TimeLimit) * 1_000_000,
-				MemoryLimit: uint64(task.Config.MemoryLimit) * 1024 * 1024,
-				ProcLimit:   50,
+				Args:        []string{"./interactor", "input", "output", "answer"},
+				Env:         []string{"PATH=/usr/bin:/bin"},
+				CPULimit:    uint64(limits.ActualTimeLimit) * 2_000_000,
+				ClockLimit:  uint64(limits.ClockLimit) * 1_000_000,
+				MemoryLimit: 512 * 1024 * 1024, // 512MB
+				ProcLimit:   defaultProcessLimit,
 				Files: []any{
-					map[string]any{"src": filepath.Join(root, testCase.InputFile)}, // input_file -> stdin
-					map[string]any{"name": "stdout", "max": 10240},                 // stdout -> stdout
-					map[string]any{"name": "stderr", "max": 10240},                 // stderr -> stderr
+					nil, // stdin <- 选手程序 stdout
+					nil, // stdout -> 选手程序 stdin
+					map[string]any{"name": "stderr", "max": stderrLimit},
 				},
 				CopyIn: map[string]any{
-					langConfig.OutputFile: map[string]any{
-						"fileId": fileId,
-					},
+					"input":  map[string]any{"src": filepath.Join(root, testCase.InputFile)},
+					"answer": map[string]any{"src": filepath.Join(root, testCase.OutputFile)},
 				},
-				CopyOut: []string{"stdout", "stderr"},
+				CopyOut: []string{"stderr"},
+			},
+		},
+		PipeMapping: []model.PipeMap{
+			{
+				In:  model.PipeIndex{Index: 0, FD: 1},
+				Out: model.PipeIndex{Index: 1, FD: 0},
+			},
+			{
+				In:  model.PipeIndex{Index: 1, FD: 1},
+				Out: model.PipeIndex{Index: 0, FD: 0},
 			},
 		},
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	data, _ := json.Marshal(payload)
-	resp, err := r.client.Post("http://localhost:5050/run", "application/json", bytes.NewReader(data))
-	if err != nil {
-		return nil, err
+	// 记录双方交互内容，便于出题人调试
+	if task.Config.Transcript {
+		for i := range payload.PipeMapping {
+			payload.PipeMapping[i].Proxy = true
+			payload.PipeMapping[i].Max = 10240
+		}
+		payload.PipeMapping[0].Name = "contestant"
+		payload.PipeMapping[1].Name = "interactor"
 	}
-	defer resp.Body.Close()
 
-	var results []GoJudgeResult
-	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
-		return nil, err
+	// 交互器重新编译后 fileId 会变化，运行时再填入
+	results, err := interactor.Run(ctx, func(interactorId string) *model.RunRequestPayload {
+		payload.Cmd[1].CopyIn["interactor"] = map[string]any{"fileId": interactorId}
+		return payload
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 	result := results[0]
-	stdout := ""
-	stderr := ""
-
-	if message, ok := result.Files["stdout"]; ok {
-		stdout = message
-	}
-	if message, ok := result.Files["stderr"]; ok {
-		stderr = message
-	}
+	r.metrics.ObserveRun(result.Status)
 
 	timeUsed := int(result.Time / 1_000_000)
 	memoryUsed := int(result.Memory / 1024)
+	stderr := result.Files["stderr"]
 
 	testResult := &model.Testcase{
 		ID:      testCase.ID,
 		Time:    &timeUsed,
 		Memory:  &memoryUsed,
 		Input:   &stdin,
-		Output:  &stdout,
 		Checker: &stderr,
 	}
+	applyStatus(&result, testResult)
+
+	if task.Config.Transcript {
+		var transcript strings.Builder
+		for _, name := range []string{"contestant", "interactor"} {
+			for _, res := range results {
+				if content, ok := res.Files[name]; ok {
+					fmt.Fprintf(&transcript, "[%s]\n%s\n", name, content)
+				}
+			}
+		}
+		content := transcript.String()
+		testResult.Transcript = &content
+	}
+
+	return testResult, parseTestlibResult(&results[1]), nil
+}
+
+// 读取文件开头部分作为摘要
+func readPreview(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return readHead(file)
+}
+
+// 读取沙箱中缓存文件的开头部分作为摘要
+func readSandboxPreview(ctx context.Context, sb Sandbox, fileId string) (string, error) {
+	file, err := sb.OpenFile(ctx, fileId)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return readHead(file)
+}
+
+// 读取至多 previewSize 字节，超出部分以 "..." 表示
+func readHead(r io.Reader) (string, error) {
+	// 多读取一个字节以判断是否被截断
+	buffer := make([]byte, previewSize+1)
+
+	n, err := io.ReadFull(r, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if n > previewSize {
+		return string(buffer[:previewSize]) + "...", nil
+	}
+	return string(buffer[:n]), nil
+}
 
+// 根据沙箱运行状态设置测试点结果
+func applyStatus(result *GoJudgeResult, testResult *model.Testcase) {
 	switch result.Status {
 	case model.StatusAccepted:
 		testResult.Verdict = model.VerdictAC
@@ -136,8 +490,17 @@ func (r *Runner) Run(task *model.JudgeTask, fileId string, testCase model.TestCa
 		testResult.Verdict = model.VerdictOLE
 	case model.StatusFileError:
 		testResult.Verdict = model.VerdictUKE
+		for _, fileError := range result.FileError {
+			// 输出文件超出大小限制
+			if fileError.Type == "CopyOutSizeExceeded" || fileError.Type == "CollectSizeExceeded" {
+				testResult.Verdict = model.VerdictOLE
+			}
+		}
 		if len(result.FileError) > 0 {
 			msg := result.FileError[0].Message
+			if msg == "" {
+				msg = fmt.Sprintf("%s: %s", result.FileError[0].Type, result.FileError[0].Name)
+			}
 			testResult.Checker = &msg
 		}
 	case model.StatusNonzeroExitStatus:
@@ -153,6 +516,4 @@ func (r *Runner) Run(task *model.JudgeTask, fileId string, testCase model.TestCa
 	default:
 		testResult.Verdict = model.VerdictUKE
 	}
-
-	return testResult, nil
 }
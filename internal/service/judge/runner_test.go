@@ -0,0 +1,91 @@
+package judge
+
+import (
+	"reisen-be/internal/model"
+	"testing"
+)
+
+func TestLanguageLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		lang       model.LanguageConfig
+		config     model.JudgeConfig
+		wantTime   int
+		wantMemory int
+	}{
+		{
+			name:       "no adjustment",
+			lang:       model.LanguageConfig{CodeLang: model.CodeLang{Ratio: 1}, MemoryRatio: 1},
+			config:     model.JudgeConfig{TimeLimit: 1000, MemoryLimit: 256},
+			wantTime:   1000,
+			wantMemory: 256,
+		},
+		{
+			name: "language ratios and extras",
+			lang: model.LanguageConfig{
+				CodeLang:    model.CodeLang{Ratio: 2},
+				TimeExtra:   500,
+				MemoryRatio: 1.5, MemoryOverhead: 64,
+			},
+			config:     model.JudgeConfig{TimeLimit: 1000, MemoryLimit: 256},
+			wantTime:   2500,
+			wantMemory: 448,
+		},
+		{
+			name:       "ratios rounded up",
+			lang:       model.LanguageConfig{CodeLang: model.CodeLang{Ratio: 1.5}, MemoryRatio: 1.1},
+			config:     model.JudgeConfig{TimeLimit: 333, MemoryLimit: 5},
+			wantTime:   500,
+			wantMemory: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := languageLimits(&tt.lang, tt.config)
+			if got.TimeLimit != tt.config.TimeLimit || got.MemoryLimit != tt.config.MemoryLimit {
+				t.Fatalf("got problem limits %d ms %d MB, want %d ms %d MB", got.TimeLimit, got.MemoryLimit, tt.config.TimeLimit, tt.config.MemoryLimit)
+			}
+			if got.ActualTimeLimit != tt.wantTime || got.ActualMemoryLimit != tt.wantMemory {
+				t.Fatalf("got %d ms %d MB, want %d ms %d MB", got.ActualTimeLimit, got.ActualMemoryLimit, tt.wantTime, tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestLanguageLimitsDefaults(t *testing.T) {
+	lang := model.LanguageConfig{CodeLang: model.CodeLang{Ratio: 2}, MemoryRatio: 1}
+	tests := []struct {
+		name   string
+		config model.JudgeConfig
+		want   model.JudgeLimits
+	}{
+		{
+			name:   "unconfigured limits derived",
+			config: model.JudgeConfig{TimeLimit: 1000, MemoryLimit: 256},
+			want: model.JudgeLimits{
+				TimeLimit: 1000, MemoryLimit: 256,
+				ActualTimeLimit: 2000, ActualMemoryLimit: 256,
+				ClockLimit: 5000, StackLimit: 256, ProcessLimit: defaultProcessLimit,
+			},
+		},
+		{
+			name: "configured limits kept",
+			config: model.JudgeConfig{
+				TimeLimit: 1000, MemoryLimit: 256,
+				ClockLimit: 1500, StackLimit: 64, ProcessLimit: 4,
+			},
+			want: model.JudgeLimits{
+				TimeLimit: 1000, MemoryLimit: 256,
+				ActualTimeLimit: 2000, ActualMemoryLimit: 256,
+				ClockLimit: 1500, StackLimit: 64, ProcessLimit: 4,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := languageLimits(&lang, tt.config); got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reisen-be/internal/model"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 沙箱后端：执行运行请求并管理沙箱内缓存的文件
+type Sandbox interface {
+	Name() string
+	Run(ctx context.Context, payload *model.RunRequestPayload) ([]GoJudgeResult, error)
+	DeleteFile(ctx context.Context, fileId string) error
+	OpenFile(ctx context.Context, fileId string) (io.ReadCloser, error)
+	Ping(ctx context.Context) error
+}
+
+// 沙箱请求在程序墙钟时间限制之外额外允许的时间，用于复制文件、排队等；也是其他请求的超时时间
+const sandboxRequestMargin = 30 * time.Second
+
+// go-judge HTTP 客户端。客户端本身不设超时，运行请求的超时由其中程序的墙钟时间限制决定
+type GoJudgeClient struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewGoJudgeClient(endpoint string) *GoJudgeClient {
+	return &GoJudgeClient{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   &http.Client{},
+	}
+}
+
+// 运行请求的超时：各命令墙钟时间限制的最大值加上余量
+func runTimeout(payload *model.RunRequestPayload) time.Duration {
+	var clockLimit uint64
+	for _, cmd := range payload.Cmd {
+		clockLimit = max(clockLimit, cmd.ClockLimit)
+	}
+	return time.Duration(clockLimit) + sandboxRequestMargin
+}
+
+func (c *GoJudgeClient) Name() string {
+	return c.endpoint
+}
+
+func (c *GoJudgeClient) Run(ctx context.Context, payload *model.RunRequestPayload) ([]GoJudgeResult, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, runTimeout(payload))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/run", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("sandbox %s returned %d: %s", c.endpoint, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var results []GoJudgeResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, err
+	}
+	if len(results) != len(payload.Cmd) {
+		return nil, fmt.Errorf("sandbox returned %d result(s) for %d command(s)", len(results), len(payload.Cmd))
+	}
+	return results, nil
+}
+
+func (c *GoJudgeClient) DeleteFile(ctx context.Context, fileId string) error {
+	ctx, cancel := context.WithTimeout(ctx, sandboxRequestMargin)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf("%s/file/%s", c.endpoint, fileId), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete file")
+	}
+	return nil
+}
+
+// 读取沙箱中缓存的文件，调用方负责关闭。读取时间受调用方的 ctx 限制
+func (c *GoJudgeClient) OpenFile(ctx context.Context, fileId string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/file/%s", c.endpoint, fileId), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("sandbox %s returned %d for file %s", c.endpoint, resp.StatusCode, fileId)
+	}
+	return resp.Body, nil
+}
+
+func (c *GoJudgeClient) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, sandboxRequestMargin)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/version", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sandbox %s returned %d", c.endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+type sandboxBackend struct {
+	sandbox *limitedSandbox
+	healthy atomic.Bool
+	load    int // 正在使用该后端的评测任务数
+}
+
+// 限制同时运行请求数的沙箱后端
+type limitedSandbox struct {
+	Sandbox
+	sem      *FairSemaphore
+	requests atomic.Uint64 // 运行请求数
+	errors   atomic.Uint64 // 请求失败数
+}
+
+func (s *limitedSandbox) Run(ctx context.Context, payload *model.RunRequestPayload) ([]GoJudgeResult, error) {
+	if err := s.sem.Acquire(ctx, fairKey(ctx)); err != nil {
+		return nil, err
+	}
+	defer s.sem.Release()
+
+	s.requests.Add(1)
+	results, err := s.Sandbox.Run(ctx, payload)
+	if err != nil {
+		s.errors.Add(1)
+	}
+	return results, err
+}
+
+// 沙箱后端的运行状态
+type SandboxStats struct {
+	Name     string
+	Healthy  bool
+	Load     int    // 正在使用该后端的评测任务数
+	Running  int    // 正在运行的请求数
+	Waiting  int    // 等待运行名额的请求数
+	Requests uint64 // 累计运行请求数
+	Errors   uint64 // 累计请求失败数
+}
+
+// 多个沙箱后端组成的池，按负载分配并定期检查健康状态
+type SandboxPool struct {
+	backends []*sandboxBackend
+	mu       sync.Mutex
+	next     int
+	probe    string // 挂载探测文件，为空时不检查
+}
+
+// 挂载探测文件的内容
+const mountProbeContent = "reisen sandbox mount probe\n"
+
+func NewSandboxPool() *SandboxPool {
+	return &SandboxPool{}
+}
+
+// 添加沙箱后端，concurrency 为该后端同时运行的请求数上限
+func (p *SandboxPool) Add(sandbox Sandbox, concurrency int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	backend := &sandboxBackend{
+		sandbox: &limitedSandbox{Sandbox: sandbox, sem: NewFairSemaphore(concurrency)},
+	}
+	backend.healthy.Store(true)
+	p.backends = append(p.backends, backend)
+}
+
+// 选择负载最低的健康后端，使用完毕后需调用 Release
+func (p *SandboxPool) Acquire() (Sandbox, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.backends) == 0 {
+		return nil, errors.New("no sandbox backend configured")
+	}
+
+	// 从上次选择的位置之后开始，负载相同时轮流分配
+	var chosen *sandboxBackend
+	for _, healthyOnly := range []bool{true, false} {
+		for i := range p.backends {
+			backend := p.backends[(p.next+i)%len(p.backends)]
+			if healthyOnly && !backend.healthy.Load() {
+				continue
+			}
+			if chosen == nil || backend.load < chosen.load {
+				chosen = backend
+			}
+		}
+		// 全部后端均不健康时仍尝试分配，由请求结果反映错误
+		if chosen != nil {
+			break
+		}
+	}
+	p.next = (p.next + 1) % len(p.backends)
+	chosen.load++
+	return chosen.sandbox, nil
+}
+
+func (p *SandboxPool) Release(sandbox Sandbox) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, backend := range p.backends {
+		if Sandbox(backend.sandbox) == sandbox {
+			backend.load--
+			return
+		}
+	}
+}
+
+// 各后端的运行状态
+func (p *SandboxPool) Stats() []SandboxStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]SandboxStats, len(p.backends))
+	for i, backend := range p.backends {
+		running, waiting := backend.sandbox.sem.Stats()
+		stats[i] = SandboxStats{
+			Name:     backend.sandbox.Name(),
+			Healthy:  backend.healthy.Load(),
+			Load:     backend.load,
+			Running:  running,
+			Waiting:  waiting,
+			Requests: backend.sandbox.requests.Load(),
+			Errors:   backend.sandbox.errors.Load(),
+		}
+	}
+	return stats
+}
+
+// 定期检查各后端健康状态
+func (p *SandboxPool) StartHealthCheck(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			p.checkHealth(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// 测试数据、答案等文件以主机路径（src）复制进沙箱，题目目录需在各沙箱主机上以相同路径挂载。
+// 在 path 写入探测文件，此后的健康检查要求沙箱能读取到该文件
+func (p *SandboxPool) SetMountProbe(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(mountProbeContent), 0644); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.probe = path
+	return nil
+}
+
+func (p *SandboxPool) checkHealth(ctx context.Context) {
+	p.mu.Lock()
+	probe := p.probe
+	p.mu.Unlock()
+
+	for _, backend := range p.backends {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := backend.sandbox.Ping(pingCtx)
+		if err == nil && probe != "" {
+			// 不占用运行名额，也不计入请求统计
+			err = checkMount(pingCtx, backend.sandbox.Sandbox, probe)
+		}
+		cancel()
+
+		healthy := err == nil
+		if backend.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("Sandbox %s is back online", backend.sandbox.Name())
+			} else {
+				log.Printf("Sandbox %s is unhealthy: %v", backend.sandbox.Name(), err)
+			}
+		}
+	}
+}
+
+// 在沙箱中读取主机上的探测文件，检查题目目录是否以相同路径挂载
+func checkMount(ctx context.Context, sb Sandbox, probe string) error {
+	results, err := sb.Run(ctx, &model.RunRequestPayload{
+		Cmd: []model.Cmd{{
+			Args: []string{"/bin/cat"},
+			Files: []any{
+				map[string]any{"src": probe},
+				map[string]any{"name": "stdout", "max": len(mountProbeContent)},
+				map[string]any{"name": "stderr", "max": stderrLimit},
+			},
+			CPULimit:    uint64(time.Second),
+			ClockLimit:  uint64(2 * time.Second),
+			MemoryLimit: 64 << 20,
+			ProcLimit:   1,
+			CopyOut:     []string{"stdout", "stderr"},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if result := results[0]; result.Status != model.StatusAccepted || result.Files["stdout"] != mountProbeContent {
+		return fmt.Errorf("cannot read %s in sandbox (%s), the problem directory must be mounted at the same path", probe, result.Status)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reisen-be/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRunTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		clock []uint64 // 各命令的墙钟时间限制(ns)
+		want  time.Duration
+	}{
+		{"no clock limit", []uint64{0}, sandboxRequestMargin},
+		{"single command", []uint64{uint64(41 * time.Second)}, 41*time.Second + sandboxRequestMargin},
+		{"longest command", []uint64{uint64(3 * time.Second), uint64(90 * time.Second)}, 90*time.Second + sandboxRequestMargin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := &model.RunRequestPayload{}
+			for _, clock := range tt.clock {
+				payload.Cmd = append(payload.Cmd, model.Cmd{ClockLimit: clock})
+			}
+			if got := runTimeout(payload); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 可区分名称、可模拟宕机的沙箱后端
+type poolTestSandbox struct {
+	*FakeSandbox
+	name string
+	down bool
+}
+
+func (s *poolTestSandbox) Name() string {
+	return s.name
+}
+
+func (s *poolTestSandbox) Ping(ctx context.Context) error {
+	if s.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// 依次向池中添加后端，down 中的后端在健康检查后被标记为不健康
+func newTestPool(names []string, down map[string]bool) *SandboxPool {
+	pool := NewSandboxPool()
+	for _, name := range names {
+		pool.Add(&poolTestSandbox{FakeSandbox: NewFakeSandbox(), name: name, down: down[name]}, 1)
+	}
+	pool.checkHealth(context.Background())
+	return pool
+}
+
+func TestSandboxPoolAcquire(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends []string
+		down     map[string]bool
+		held     int      // 先获取且不释放的次数
+		want     []string // 之后每次获取并立即释放时选中的后端
+	}{
+		{"idle backends take turns", []string{"a", "b", "c"}, nil, 0, []string{"a", "b", "c", "a"}},
+		{"least loaded preferred", []string{"a", "b"}, nil, 1, []string{"b", "b", "b"}},
+		{"unhealthy backend skipped", []string{"a", "b", "c"}, map[string]bool{"b": true}, 0, []string{"a", "c", "c", "a"}},
+		{"busy healthy backend preferred over idle unhealthy one", []string{"a", "b"}, map[string]bool{"b": true}, 1, []string{"a", "a"}},
+		{"all unhealthy still assigned", []string{"a", "b"}, map[string]bool{"a": true, "b": true}, 0, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newTestPool(tt.backends, tt.down)
+			for range tt.held {
+				if _, err := pool.Acquire(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got []string
+			for range tt.want {
+				sandbox, err := pool.Acquire()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, sandbox.Name())
+				pool.Release(sandbox)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSandboxPoolRelease(t *testing.T) {
+	pool := newTestPool([]string{"a", "b"}, nil)
+	first, _ := pool.Acquire()
+	second, _ := pool.Acquire()
+	pool.Release(first)
+
+	loads := map[string]int{}
+	for _, stats := range pool.Stats() {
+		loads[stats.Name] = stats.Load
+	}
+	if loads[first.Name()] != 0 || loads[second.Name()] != 1 {
+		t.Fatalf("got loads %v after releasing %s", loads, first.Name())
+	}
+}
+
+func TestSandboxPoolEmpty(t *testing.T) {
+	if _, err := NewSandboxPool().Acquire(); err == nil {
+		t.Fatal("expected an error without backends")
+	}
+}
+
+func TestSandboxPoolMountProbe(t *testing.T) {
+	tests := []struct {
+		name        string
+		removed     bool  // 探测文件在沙箱一侧不可见
+		runErr      error // 沙箱运行请求失败
+		wantHealthy bool
+	}{
+		{"probe readable", false, nil, true},
+		{"probe missing", true, nil, false},
+		{"run failed", false, errors.New("sandbox error"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sandbox := &poolTestSandbox{FakeSandbox: NewFakeSandbox(), name: "a"}
+			if tt.runErr != nil {
+				sandbox.RunFunc = func(payload *model.RunRequestPayload) ([]GoJudgeResult, error) {
+					return nil, tt.runErr
+				}
+			}
+			pool := NewSandboxPool()
+			pool.Add(sandbox, 1)
+
+			probe := filepath.Join(t.TempDir(), "problems", ".sandbox-probe")
+			if err := pool.SetMountProbe(probe); err != nil {
+				t.Fatal(err)
+			}
+			if tt.removed {
+				if err := os.Remove(probe); err != nil {
+					t.Fatal(err)
+				}
+			}
+			pool.checkHealth(context.Background())
+
+			if got := pool.Stats()[0].Healthy; got != tt.wantHealthy {
+				t.Fatalf("got healthy %v, want %v", got, tt.wantHealthy)
+			}
+			if got := pool.Stats()[0].Requests; got != 0 {
+				t.Fatalf("probe counted as %d run request(s)", got)
+			}
+		})
+	}
+}
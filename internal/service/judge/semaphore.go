@@ -0,0 +1,129 @@
+package judge
+
+import (
+	"context"
+	"sync"
+)
+
+type fairKeyType struct{}
+
+// 为请求标记所属的评测任务，同一沙箱后端按任务轮流分配运行名额
+func WithFairKey(ctx context.Context, key any) context.Context {
+	return context.WithValue(ctx, fairKeyType{}, key)
+}
+
+func fairKey(ctx context.Context) any {
+	return ctx.Value(fairKeyType{})
+}
+
+// 计数信号量，等待者按所属任务轮流获得名额，避免测试点多的任务长期占满沙箱
+type FairSemaphore struct {
+	capacity int
+	inUse    int
+	waiters  map[any][]chan struct{} // 各任务的等待队列
+	order    []any                   // 有等待者的任务，按轮转顺序排列
+	mu       sync.Mutex
+}
+
+func NewFairSemaphore(capacity int) *FairSemaphore {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &FairSemaphore{
+		capacity: capacity,
+		waiters:  make(map[any][]chan struct{}),
+	}
+}
+
+// 获取一个名额，ctx 取消时返回错误
+func (s *FairSemaphore) Acquire(ctx context.Context, key any) error {
+	s.mu.Lock()
+	if s.inUse < s.capacity && len(s.order) == 0 {
+		s.inUse++
+		s.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	if _, ok := s.waiters[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.waiters[key] = append(s.waiters[key], ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		select {
+		case <-ready:
+			// 取消的同时已获得名额，转交给其他等待者
+			s.release()
+		default:
+			s.removeWaiter(key, ready)
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *FairSemaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.release()
+}
+
+// 当前占用与等待的名额数
+func (s *FairSemaphore) Stats() (inUse, waiting int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, queue := range s.waiters {
+		waiting += len(queue)
+	}
+	return s.inUse, waiting
+}
+
+// 将名额交给下一个任务的首个等待者，没有等待者时归还名额
+func (s *FairSemaphore) release() {
+	if len(s.order) == 0 {
+		s.inUse--
+		return
+	}
+
+	key := s.order[0]
+	queue := s.waiters[key]
+	ready := queue[0]
+	s.order = s.order[1:]
+	if len(queue) > 1 {
+		s.waiters[key] = queue[1:]
+		s.order = append(s.order, key)
+	} else {
+		delete(s.waiters, key)
+	}
+	close(ready)
+}
+
+func (s *FairSemaphore) removeWaiter(key any, ready chan struct{}) {
+	queue := s.waiters[key]
+	for i, waiter := range queue {
+		if waiter == ready {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) > 0 {
+		s.waiters[key] = queue
+		return
+	}
+	delete(s.waiters, key)
+	for i, k := range s.order {
+		if k == key {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
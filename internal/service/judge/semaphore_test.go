@@ -0,0 +1,106 @@
+package judge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFairSemaphoreRoundRobin(t *testing.T) {
+	tests := []struct {
+		name    string
+		waiters []string // 依次排队的等待者所属任务
+		want    []string // 获得名额的顺序
+	}{
+		{"single key keeps fifo order", []string{"a", "a", "a"}, []string{"a", "a", "a"}},
+		{"keys take turns", []string{"a", "a", "a", "b"}, []string{"a", "b", "a", "a"}},
+		{"three keys", []string{"a", "a", "b", "b", "c"}, []string{"a", "b", "c", "a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewFairSemaphore(1)
+			if err := s.Acquire(context.Background(), "holder"); err != nil {
+				t.Fatal(err)
+			}
+
+			acquired := make(chan string, len(tt.waiters))
+			for i, key := range tt.waiters {
+				go func() {
+					if err := s.Acquire(context.Background(), key); err == nil {
+						acquired <- key
+					}
+				}()
+				waitForWaiters(t, s, i+1)
+			}
+
+			for i, want := range tt.want {
+				s.Release()
+				if got := <-acquired; got != want {
+					t.Fatalf("acquisition %d: got %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFairSemaphoreCancel(t *testing.T) {
+	s := NewFairSemaphore(1)
+	if err := s.Acquire(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Acquire(ctx, "b") }()
+	waitForWaiters(t, s, 1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	s.Release()
+	if inUse, waiting := s.Stats(); inUse != 0 || waiting != 0 {
+		t.Fatalf("got inUse=%d waiting=%d, want 0 0", inUse, waiting)
+	}
+}
+
+func TestFairSemaphoreCapacity(t *testing.T) {
+	tests := []struct {
+		capacity int
+		want     int
+	}{
+		{0, 1},
+		{1, 1},
+		{3, 3},
+	}
+	for _, tt := range tests {
+		s := NewFairSemaphore(tt.capacity)
+		for i := 0; i < tt.want; i++ {
+			if err := s.Acquire(context.Background(), i); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := s.Acquire(ctx, "extra")
+		cancel()
+		if err == nil {
+			t.Errorf("capacity %d: acquired more than %d", tt.capacity, tt.want)
+		}
+	}
+}
+
+// 等待排队的等待者数量达到 n
+func waitForWaiters(t *testing.T, s *FairSemaphore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, waiting := s.Stats(); waiting >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d waiters", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,265 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reisen-be/internal/model"
+	"sync"
+)
+
+// 子任务评测计划
+type subtaskPlan struct {
+	config  model.SubtaskConfig
+	pos     int   // 在 task.Subtasks 中的位置
+	indices []int // 包含的测试点下标
+	deps    []int // 依赖子任务在 task.Subtasks 中的位置
+}
+
+// 检查子任务配置，并按依赖关系排序
+func planSubtasks(config model.JudgeConfig) ([]subtaskPlan, error) {
+	if len(config.Subtasks) == 0 {
+		return nil, nil
+	}
+
+	testcaseIndex := make(map[int]int, len(config.TestCases))
+	for i, tc := range config.TestCases {
+		testcaseIndex[tc.ID] = i
+	}
+	subtaskIndex := make(map[int]int, len(config.Subtasks))
+	for i, subtask := range config.Subtasks {
+		if _, ok := subtaskIndex[subtask.ID]; ok {
+			return nil, fmt.Errorf("duplicate subtask %d", subtask.ID)
+		}
+		subtaskIndex[subtask.ID] = i
+	}
+
+	plans := make([]subtaskPlan, len(config.Subtasks))
+	for i, subtask := range config.Subtasks {
+		switch subtask.Policy {
+		case "", model.SubtaskPolicySum, model.SubtaskPolicyMin, model.SubtaskPolicyAll:
+		default:
+			return nil, fmt.Errorf("unknown policy %q of subtask %d", subtask.Policy, subtask.ID)
+		}
+		if len(subtask.TestCases) == 0 {
+			return nil, fmt.Errorf("subtask %d has no testcase", subtask.ID)
+		}
+
+		plan := subtaskPlan{config: subtask, pos: i}
+		seen := map[int]bool{}
+		for _, id := range subtask.TestCases {
+			idx, ok := testcaseIndex[id]
+			if !ok {
+				return nil, fmt.Errorf("subtask %d refers to unknown testcase %d", subtask.ID, id)
+			}
+			if !seen[idx] {
+				seen[idx] = true
+				plan.indices = append(plan.indices, idx)
+			}
+		}
+		for _, id := range subtask.Dependencies {
+			dep, ok := subtaskIndex[id]
+			if !ok {
+				return nil, fmt.Errorf("subtask %d depends on unknown subtask %d", subtask.ID, id)
+			}
+			plan.deps = append(plan.deps, dep)
+		}
+		plans[i] = plan
+	}
+
+	// 不属于任何子任务的测试点不会被评测，视为配置错误
+	covered := make([]bool, len(config.TestCases))
+	for _, plan := range plans {
+		for _, idx := range plan.indices {
+			covered[idx] = true
+		}
+	}
+	for idx, tc := range config.TestCases {
+		if !covered[idx] {
+			return nil, fmt.Errorf("testcase %d does not belong to any subtask", tc.ID)
+		}
+	}
+
+	// 拓扑排序，保证依赖的子任务先评测
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(plans))
+	order := make([]subtaskPlan, 0, len(plans))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("circular dependency on subtask %d", plans[i].config.ID)
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		for _, dep := range plans[i].deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		order = append(order, plans[i])
+		return nil
+	}
+	for i := range plans {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// 按依赖顺序评测各子任务并计分
+func (d *Dispatcher) judgeSubtasks(ctx context.Context, jc *judgeContext, plans []subtaskPlan) {
+	task := jc.task
+
+	for _, plan := range plans {
+		result := &task.Subtasks[plan.pos]
+
+		// 依赖的子任务未满分时跳过
+		satisfied := true
+		for _, dep := range plan.deps {
+			if task.Subtasks[dep].Score < task.Subtasks[dep].FullScore || task.Subtasks[dep].Verdict != model.VerdictAC {
+				satisfied = false
+				break
+			}
+		}
+		if !satisfied {
+			result.Verdict = model.VerdictSKP
+			result.Score = 0
+			d.skipTestcases(jc, plan.indices)
+			continue
+		}
+
+		// 只有满分才计分的子任务可以在首个未通过的测试点后停止
+		execution := model.ExecutionAll
+		if policy := plan.config.Policy; policy != "" && policy != model.SubtaskPolicySum {
+			execution = task.Config.Execution
+			if execution == "" && task.Config.SkipOnFail {
+				execution = model.ExecutionOrdered
+			}
+		}
+		d.runTestcases(ctx, jc, plan.indices, execution)
+
+		result.Verdict = model.VerdictAC
+		for _, idx := range plan.indices {
+			verdict := task.Testcases[idx].Verdict
+			if verdict != model.VerdictAC && verdict != model.VerdictSKP {
+				result.Verdict = verdict
+				break
+			}
+		}
+		result.Score = subtaskScore(plan, jc.points)
+	}
+}
+
+// 计算子任务得分
+func subtaskScore(plan subtaskPlan, points []float64) int {
+	ratio := 0.0
+	switch plan.config.Policy {
+	case model.SubtaskPolicyMin:
+		ratio = 1
+		for _, idx := range plan.indices {
+			ratio = math.Min(ratio, points[idx])
+		}
+	case model.SubtaskPolicyAll:
+		ratio = 1
+		for _, idx := range plan.indices {
+			if points[idx] < 1 {
+				ratio = 0
+				break
+			}
+		}
+	default:
+		for _, idx := range plan.indices {
+			ratio += points[idx]
+		}
+		ratio /= float64(len(plan.indices))
+	}
+	return int(math.Round(ratio * float64(plan.config.Score)))
+}
+
+// 按执行方式评测一组测试点，未评测的测试点标记为跳过
+func (d *Dispatcher) runTestcases(ctx context.Context, jc *judgeContext, indices []int, execution string) {
+	switch execution {
+	case model.ExecutionOrdered:
+		for k, idx := range indices {
+			d.runTestcase(ctx, jc, idx)
+			if jc.task.Testcases[idx].Verdict != model.VerdictAC {
+				d.skipTestcases(jc, indices[k+1:])
+				return
+			}
+		}
+
+	case model.ExecutionCancel:
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		for _, idx := range indices {
+			wg.Add(1)
+			go func(idx int) {
+				defer wg.Done()
+				if jc.judged[idx] || runCtx.Err() != nil {
+					return
+				}
+				result, points := d.judgeTestcase(runCtx, jc, jc.task.Config.TestCases[idx])
+				// 因其他测试点未通过而中断的测试点不记录结果，稍后标记为跳过
+				if result.Verdict == model.VerdictUKE && runCtx.Err() != nil && ctx.Err() == nil {
+					return
+				}
+				d.recordTestcase(jc, idx, result, points)
+				if result.Verdict != model.VerdictAC {
+					cancel()
+				}
+			}(idx)
+		}
+		wg.Wait()
+		d.skipTestcases(jc, indices)
+
+	default:
+		var wg sync.WaitGroup
+		for _, idx := range indices {
+			wg.Add(1)
+			go func(idx int) {
+				defer wg.Done()
+				d.runTestcase(ctx, jc, idx)
+			}(idx)
+		}
+		wg.Wait()
+	}
+}
+
+// 评测单个测试点，已评测过的测试点（被多个子任务共享）直接复用结果
+func (d *Dispatcher) runTestcase(ctx context.Context, jc *judgeContext, idx int) {
+	if jc.judged[idx] {
+		return
+	}
+	result, points := d.judgeTestcase(ctx, jc, jc.task.Config.TestCases[idx])
+	d.recordTestcase(jc, idx, result, points)
+}
+
+// 记录测试点结果并通知评测进度
+func (d *Dispatcher) recordTestcase(jc *judgeContext, idx int, result model.Testcase, points float64) {
+	jc.task.Testcases[idx], jc.points[idx] = result, points
+	jc.judged[idx] = true
+	jc.progress <- idx
+}
+
+// 将尚未评测的测试点标记为跳过
+func (d *Dispatcher) skipTestcases(jc *judgeContext, indices []int) {
+	for _, idx := range indices {
+		if jc.judged[idx] {
+			continue
+		}
+		jc.task.Testcases[idx] = model.Testcase{
+			ID:      jc.task.Config.TestCases[idx].ID,
+			Verdict: model.VerdictSKP,
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package judge
+
+import (
+	"reisen-be/internal/model"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testcases(ids ...int) []model.TestCaseConfig {
+	var result []model.TestCaseConfig
+	for _, id := range ids {
+		result = append(result, model.TestCaseConfig{ID: id})
+	}
+	return result
+}
+
+func TestPlanSubtasks(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    model.JudgeConfig
+		wantOrder []int // 评测顺序（子任务编号）
+		wantErr   string
+	}{
+		{
+			name:   "no subtasks",
+			config: model.JudgeConfig{TestCases: testcases(1, 2)},
+		},
+		{
+			name: "dependencies come first",
+			config: model.JudgeConfig{
+				TestCases: testcases(1, 2, 3),
+				Subtasks: []model.SubtaskConfig{
+					{ID: 1, TestCases: []int{3}, Dependencies: []int{2}},
+					{ID: 2, TestCases: []int{1, 2}},
+				},
+			},
+			wantOrder: []int{2, 1},
+		},
+		{
+			name: "duplicate subtask",
+			config: model.JudgeConfig{
+				TestCases: testcases(1),
+				Subtasks:  []model.SubtaskConfig{{ID: 1, TestCases: []int{1}}, {ID: 1, TestCases: []int{1}}},
+			},
+			wantErr: "duplicate subtask 1",
+		},
+		{
+			name: "unknown policy",
+			config: model.JudgeConfig{
+				TestCases: testcases(1),
+				Subtasks:  []model.SubtaskConfig{{ID: 1, Policy: "max", TestCases: []int{1}}},
+			},
+			wantErr: "unknown policy",
+		},
+		{
+			name: "empty subtask",
+			config: model.JudgeConfig{
+				TestCases: testcases(1),
+				Subtasks:  []model.SubtaskConfig{{ID: 1, TestCases: []int{1}}, {ID: 2}},
+			},
+			wantErr: "subtask 2 has no testcase",
+		},
+		{
+			name: "unknown testcase",
+			config: model.JudgeConfig{
+				TestCases: testcases(1),
+				Subtasks:  []model.SubtaskConfig{{ID: 1, TestCases: []int{1, 5}}},
+			},
+			wantErr: "unknown testcase 5",
+		},
+		{
+			name: "unknown dependency",
+			config: model.JudgeConfig{
+				TestCases: testcases(1),
+				Subtasks:  []model.SubtaskConfig{{ID: 1, TestCases: []int{1}, Dependencies: []int{9}}},
+			},
+			wantErr: "unknown subtask 9",
+		},
+		{
+			name: "testcase outside every subtask",
+			config: model.JudgeConfig{
+				TestCases: testcases(1, 2),
+				Subtasks:  []model.SubtaskConfig{{ID: 1, TestCases: []int{1}}},
+			},
+			wantErr: "testcase 2 does not belong to any subtask",
+		},
+		{
+			name: "circular dependency",
+			config: model.JudgeConfig{
+				TestCases: testcases(1, 2),
+				Subtasks: []model.SubtaskConfig{
+					{ID: 1, TestCases: []int{1}, Dependencies: []int{2}},
+					{ID: 2, TestCases: []int{2}, Dependencies: []int{1}},
+				},
+			},
+			wantErr: "circular dependency",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plans, err := planSubtasks(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var order []int
+			for _, plan := range plans {
+				order = append(order, plan.config.ID)
+			}
+			if !slices.Equal(order, tt.wantOrder) {
+				t.Fatalf("got order %v, want %v", order, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestSubtaskScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		points []float64
+		want   int
+	}{
+		{"sum of full marks", model.SubtaskPolicySum, []float64{1, 1, 1, 1}, 40},
+		{"sum of partial marks", model.SubtaskPolicySum, []float64{1, 0.5, 0, 1}, 25},
+		{"default policy is sum", "", []float64{1, 0, 1, 0}, 20},
+		{"min takes the lowest ratio", model.SubtaskPolicyMin, []float64{1, 0.5, 0.75, 1}, 20},
+		{"min of full marks", model.SubtaskPolicyMin, []float64{1, 1, 1, 1}, 40},
+		{"all requires every testcase", model.SubtaskPolicyAll, []float64{1, 1, 0.9, 1}, 0},
+		{"all of full marks", model.SubtaskPolicyAll, []float64{1, 1, 1, 1}, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := subtaskPlan{
+				config:  model.SubtaskConfig{Score: 40, Policy: tt.policy},
+				indices: []int{0, 1, 2, 3},
+			}
+			if got := subtaskScore(plan, tt.points); got != tt.want {
+				t.Fatalf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"reisen-be/internal/model"
+	"strings"
+)
+
+// testlib 格式的输入校验器：从标准输入读取数据，合法时以 0 退出，否则在 stderr 输出原因
+type Validator struct {
+	program *Program
+}
+
+// 编译题目目录下的校验器，root 为题目目录
+func NewValidator(ctx context.Context, sb Sandbox, root string, program model.ProgramConfig, programs *ProgramCache) (*Validator, error) {
+	compiled, err := programs.Load(ctx, sb, root, program)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{program: compiled}, nil
+}
+
+// 校验单个输入文件，返回的错误表示校验器本身运行失败
+func (v *Validator) Validate(ctx context.Context, inputFile string) (bool, string, error) {
+	results, err := v.program.Run(ctx, func(fileId string) *model.RunRequestPayload {
+		return validatorPayload(fileId, inputFile)
+	})
+	if err != nil {
+		return false, "", err
+	}
+
+	result := &results[0]
+	message := strings.TrimSpace(result.Files["stderr"])
+	switch result.Status {
+	case model.StatusAccepted:
+		return true, message, nil
+	case model.StatusNonzeroExitStatus:
+		return false, message, nil
+	default:
+		return false, "", fmt.Errorf("validator failed: %s. %s", result.Status, message)
+	}
+}
+
+func validatorPayload(fileId, inputFile string) *model.RunRequestPayload {
+	return &model.RunRequestPayload{
+		Cmd: []model.Cmd{
+			{
+				Args:        []string{"./validator"},
+				Env:         []string{"PATH=/usr/bin:/bin"},
+				CPULimit:    10_000_000_000,    // 10s
+				ClockLimit:  20_000_000_000,    // 20s
+				MemoryLimit: 512 * 1024 * 1024, // 512MB
+				ProcLimit:   50,
+				Files: []any{
+					map[string]any{"src": inputFile},
+					map[string]any{"name": "stdout", "max": 10240},
+					map[string]any{"name": "stderr", "max": 10240},
+				},
+				CopyIn: map[string]any{
+					"validator": map[string]any{"fileId": fileId},
+				},
+				CopyOut: []string{"stdout", "stderr"},
+			},
+		},
+	}
+}
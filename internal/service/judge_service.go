@@ -2,25 +2,58 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"reisen-be/internal/config"
 	"reisen-be/internal/filesystem"
 	"reisen-be/internal/model"
 	"reisen-be/internal/repository"
 	"reisen-be/internal/service/judge"
 	"reisen-be/internal/websocket"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxJudgeAttempts  = 3 // 同一提交最多被认领评测的次数
+	saveResultRetries = 5 // 保存评测结果的最大尝试次数
 )
 
+var ErrJudgeShuttingDown = errors.New("judge service is shutting down")
+
+// 停止评测服务所需的状态
+type judgeLifecycle struct {
+	closed     atomic.Bool        // 已停止接收提交
+	stopClaim  context.CancelFunc // 停止认领新任务
+	abortJudge context.CancelFunc // 中断正在进行的评测
+	pollerDone chan struct{}      // 认领协程已退出
+	drained    chan struct{}      // 全部评测结果已处理
+}
+
 type JudgeService struct {
 	submissionRepo     *repository.SubmissionRepository
 	judgementRepo      *repository.JudgementRepository
 	problemRepo        *repository.ProblemRepository
 	userRepo           *repository.UserRepository
+	queueRepo          *repository.JudgeQueueRepository
 	
 	dispatcher         *judge.Dispatcher
+	sandboxes          *judge.SandboxPool
+	metrics            *judge.Metrics
 	problemFilesystem  *filesystem.ProblemFilesystem
 	contestService     *ContestService
+
+	instance           string        // 队列租约持有者
+	lease              time.Duration // 队列租约时长
+	wakeup             chan struct{} // 通知轮询协程认领新任务
+	held               map[model.SubmissionId]bool // 已交给 Dispatcher 且尚未处理完结果的提交，只续约这些租约
+	heldMu             sync.Mutex
+	lifecycle          *judgeLifecycle
 }
 
 func NewJudgeService(
@@ -28,33 +61,290 @@ func NewJudgeService(
     judgementRepo *repository.JudgementRepository,
     problemRepo *repository.ProblemRepository,
     userRepo *repository.UserRepository,
+    queueRepo *repository.JudgeQueueRepository,
 		problemFilesystem * filesystem.ProblemFilesystem,
 	  submissionWs      *websocket.SubmissionWs,
 		contestService     *ContestService,
+		sandboxes          *judge.SandboxPool,
+		languages          *config.LanguageRegistry,
+		compileCacheSize   int,
+		compileCacheTTL    time.Duration,
     workers int,
+    instance string,
+    lease time.Duration,
+    pollInterval time.Duration,
 ) *JudgeService {
-    compiler := judge.NewCompiler()
-    runner := judge.NewRunner()
+    metrics := judge.NewMetrics()
+    compiler := judge.NewCompiler(languages, metrics)
+    runner := judge.NewRunner(languages, metrics)
+    compileCache := judge.NewCompileCache(compiler, compileCacheSize, compileCacheTTL)
     
-    // 默认使用严格判分器，实际会根据题目配置选择
-    checker, _ := judge.NewChecker(model.JudgeConfig{CheckerType: "loose"})
-    dispatcher := judge.NewDispatcher(workers, compiler, runner, checker, problemFilesystem, submissionWs)
+    // 判分器由 Dispatcher 根据每道题目的配置创建
+    dispatcher := judge.NewDispatcher(workers, sandboxes, compiler, compileCache, runner, metrics, problemFilesystem, submissionWs)
     
-    ctx := context.Background()
-    dispatcher.Start(ctx)
+    judgeCtx, abortJudge := context.WithCancel(context.Background())
+    dispatcher.Start(judgeCtx)
+    claimCtx, stopClaim := context.WithCancel(context.Background())
 
     s := &JudgeService{
         submissionRepo:     submissionRepo,
         judgementRepo:      judgementRepo,
         problemRepo:        problemRepo,
         userRepo:           userRepo,
+        queueRepo:          queueRepo,
         dispatcher:         dispatcher,
+        sandboxes:          sandboxes,
+        metrics:            metrics,
         problemFilesystem:  problemFilesystem,
 				contestService:     contestService,
+        instance:           instance,
+        lease:              lease,
+        wakeup:             make(chan struct{}, 1),
+        held:               make(map[model.SubmissionId]bool),
+        lifecycle: &judgeLifecycle{
+            stopClaim:  stopClaim,
+            abortJudge: abortJudge,
+            pollerDone: make(chan struct{}),
+            drained:    make(chan struct{}),
+        },
+    }
+
+    // 恢复上次运行未完成的评测
+    if err := s.queueRepo.ReleaseAll(instance); err != nil {
+        log.Printf("Failed to release judge queue leases of %s: %v", instance, err)
     }
+    if count, err := s.queueRepo.RequeueUnfinished(); err != nil {
+        log.Printf("Failed to requeue unfinished submissions: %v", err)
+    } else if count > 0 {
+        log.Printf("Requeued %d unfinished submission(s)", count)
+    }
+
+    go s.pollQueue(claimCtx, pollInterval)
+    go s.renewLeases()
+    go s.consumeResults()
 		return s
 }
 
+// 返回在事务 tx 中保存数据的服务副本
+func (s *JudgeService) withTx(tx *gorm.DB) *JudgeService {
+	return &JudgeService{
+		submissionRepo:    s.submissionRepo.WithTx(tx),
+		judgementRepo:     s.judgementRepo.WithTx(tx),
+		problemRepo:       s.problemRepo.WithTx(tx),
+		userRepo:          s.userRepo.WithTx(tx),
+		queueRepo:         s.queueRepo.WithTx(tx),
+		dispatcher:        s.dispatcher,
+		sandboxes:         s.sandboxes,
+		metrics:           s.metrics,
+		problemFilesystem: s.problemFilesystem,
+		contestService:    s.contestService.withTx(tx),
+		instance:          s.instance,
+		lease:             s.lease,
+		wakeup:            s.wakeup,
+		lifecycle:         s.lifecycle,
+	}
+}
+
+// 停止评测服务：不再接收提交与认领任务，等待已认领的任务评测完毕并保存结果。
+// ctx 结束时中断剩余评测，未完成的提交释放租约后由其他实例或下次启动时重新评测
+func (s *JudgeService) Shutdown(ctx context.Context) error {
+	s.lifecycle.closed.Store(true)
+	s.lifecycle.stopClaim()
+	<-s.lifecycle.pollerDone
+	s.dispatcher.Stop()
+
+	var err error
+	select {
+	case <-s.lifecycle.drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Printf("Judge queue was not drained in time, aborting in-flight tasks")
+		s.lifecycle.abortJudge()
+		<-s.lifecycle.drained
+	}
+
+	if releaseErr := s.queueRepo.ReleaseAll(s.instance); releaseErr != nil {
+		log.Printf("Failed to release judge queue leases of %s: %v", s.instance, releaseErr)
+	}
+	return err
+}
+
+// 通知轮询协程尽快认领任务
+func (s *JudgeService) wake() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// 从评测队列认领任务交给 Dispatcher
+func (s *JudgeService) pollQueue(ctx context.Context, interval time.Duration) {
+	defer close(s.lifecycle.pollerDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if idle := s.dispatcher.Idle(); idle > 0 {
+			items, err := s.queueRepo.Claim(s.instance, s.lease, idle)
+			if err != nil {
+				log.Printf("Failed to claim judge tasks: %v", err)
+			}
+			for _, item := range items {
+				s.hold(item.SubmissionID)
+				// 未交给 Dispatcher 的提交不再续约，租约过期后重新认领
+				if !s.dispatchQueued(item) {
+					s.unhold(item.SubmissionID)
+				}
+			}
+		}
+
+		select {
+		case <-s.wakeup:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 定期续约正在评测的任务，直到全部评测结果处理完毕
+func (s *JudgeService) renewLeases() {
+	ticker := time.NewTicker(s.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.queueRepo.Renew(s.instance, s.heldSubmissions(), s.lease); err != nil {
+				log.Printf("Failed to renew judge queue leases: %v", err)
+			}
+		case <-s.lifecycle.drained:
+			return
+		}
+	}
+}
+
+func (s *JudgeService) hold(id model.SubmissionId) {
+	s.heldMu.Lock()
+	defer s.heldMu.Unlock()
+	s.held[id] = true
+}
+
+func (s *JudgeService) unhold(id model.SubmissionId) {
+	s.heldMu.Lock()
+	defer s.heldMu.Unlock()
+	delete(s.held, id)
+}
+
+func (s *JudgeService) heldSubmissions() []model.SubmissionId {
+	s.heldMu.Lock()
+	defer s.heldMu.Unlock()
+	ids := make([]model.SubmissionId, 0, len(s.held))
+	for id := range s.held {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// 将已认领的提交交给 Dispatcher 评测，返回是否已交给 Dispatcher
+func (s *JudgeService) dispatchQueued(item model.JudgeQueueItem) bool {
+	id := item.SubmissionID
+	submission, err := s.submissionRepo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 提交已被删除
+		s.queueRepo.Delete(id)
+		return false
+	}
+	if err != nil {
+		log.Printf("Failed to load submission %d, it will be retried after the lease expires: %v", id, err)
+		return false
+	}
+
+	// 多次认领仍未完成（如结果始终无法保存），不再重试
+	if item.Attempts >= maxJudgeAttempts {
+		log.Printf("Submission %d failed to be judged after %d attempts", id, item.Attempts)
+		s.abandon(submission)
+		return false
+	}
+
+	task, err := s.newJudgeTask(submission)
+	if err != nil {
+		log.Printf("Failed to prepare submission %d: %v", id, err)
+		s.abandon(submission)
+		return false
+	}
+
+	// 认领数量不超过空闲 worker 数，此处不会被拒绝；若被拒绝，租约过期后重新认领
+	if !s.dispatcher.Submit(task) {
+		log.Printf("Dispatcher is busy, submission %d will be retried after the lease expires", id)
+		return false
+	}
+	return true
+}
+
+// 将无法评测的提交标记为未知错误并移出队列
+func (s *JudgeService) abandon(submission *model.Submission) {
+	submission.Verdict = model.VerdictUKE
+	err := s.saveResult(submission)
+	if err == nil {
+		return
+	}
+	log.Printf("Failed to save result of abandoned submission %d: %v", submission.ID, err)
+
+	// 无法保存评测结果时只更新评测记录
+	err = s.submissionRepo.Transaction(func(tx *gorm.DB) error {
+		submission.Verdict = model.VerdictUKE
+		if err := s.submissionRepo.WithTx(tx).Update(submission); err != nil {
+			return err
+		}
+		return s.queueRepo.WithTx(tx).Delete(submission.ID)
+	})
+	if err != nil {
+		log.Printf("Failed to abandon submission %d: %v", submission.ID, err)
+	}
+}
+
+// 根据提交记录与题目当前配置创建评测任务（时空限制以题目信息为准）
+func (s *JudgeService) newJudgeTask(submission *model.Submission) (*model.JudgeTask, error) {
+	problem, err := s.problemRepo.GetByID(submission.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.problemFilesystem.GetJudgeConfig(submission.ProblemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get test cases: %v", err)
+	}
+
+	judgeConfig := *config
+	judgeConfig.TimeLimit = problem.LimitTime
+	judgeConfig.MemoryLimit = problem.LimitMemory
+	judgeConfig.Type = problem.Type
+
+	// ACM 赛制只关心首个未通过的测试点
+	if judgeConfig.Execution == "" && submission.ContestID != nil {
+		contest, err := s.contestService.GetContest(*submission.ContestID)
+		if err != nil {
+			return nil, err
+		}
+		if contest.Rule == model.ContestRuleACM {
+			judgeConfig.Execution = model.ExecutionOrdered
+		}
+	}
+
+	// 测试点以当前配置为准（重测时测试数据可能已改变）
+	task := &model.JudgeTask{
+		Submission: *submission,
+		Config:     judgeConfig,
+	}
+	task.Testcases = make(model.TestcaseList, len(config.TestCases))
+	for i := range task.Testcases {
+		task.Testcases[i].ID = config.TestCases[i].ID
+		task.Testcases[i].Verdict = model.VerdictPD
+	}
+	return task, nil
+}
+
 
 // 根据用户提交更新其 Judgement 的通过信息
 func (s *JudgeService) UpdateJudgement(submission *model.Submission) error {
@@ -104,42 +394,214 @@ func (s *JudgeService) UpdateJudgement(submission *model.Submission) error {
 		}
 	}
 
-	s.judgementRepo.Update(judgement)
-	return nil
+	return s.judgementRepo.Update(judgement)
 }
 
-func (s *JudgeService) processResult() error {
-	// 等待结果
-	submission := <-s.dispatcher.Results()
-
-	// 保存更新
-	if err := s.submissionRepo.Update(submission); err != nil {
+// 重测后根据用户本题全部已评测的记录重新计算其 Judgement
+func (s *JudgeService) RecomputeJudgement(problemID model.ProblemId, userID model.UserId) error {
+	problem, err := s.problemRepo.GetByID(problemID)
+	if err != nil {
 		return err
 	}
+	submissions, err := s.submissionRepo.ListFinishedByProblem(problemID, userID)
+	if err != nil {
+		return err
+	}
+
+	judgement := &model.Judgement{
+		ProblemID:  problemID,
+		UserID:     userID,
+		Judge:      "incorrect",
+		Difficulty: problem.Difficulty,
+	}
+	best := -1
+	for _, submission := range submissions {
+		if submission.Verdict == model.VerdictAC {
+			judgement.Judge = "correct"
+			judgement.Stamp = &submission.SubmittedAt
+			break
+		}
+		if submission.Score != nil && *submission.Score > best {
+			best = *submission.Score
+			judgement.Judge = strconv.Itoa(best)
+		}
+	}
 
-	s.UpdateJudgement(submission)
-	s.contestService.UpdateRanking(submission)
+	// 重测前已通过时保留原通过时间
+	if previous, err := s.judgementRepo.GetByID(problemID, userID); err == nil {
+		if previous.Judge == "correct" && judgement.Judge == "correct" && previous.Stamp != nil {
+			judgement.Stamp = previous.Stamp
+		}
+	}
+	return s.judgementRepo.Update(judgement)
+}
 
-	// 更新题目统计信息
-	if submission.Verdict == model.VerdictAC {
-		if err := s.problemRepo.IncreaseSubmitCorrect(submission.ProblemID); err != nil {
+// 重测符合条件的记录，返回重新加入评测队列的记录数
+func (s *JudgeService) Rejudge(filter *model.RejudgeFilter) (int, error) {
+	ids, err := s.submissionRepo.ListRejudgeIDs(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, id := range ids {
+		var queued bool
+		err := s.submissionRepo.Transaction(func(tx *gorm.DB) error {
+			queued, err = s.withTx(tx).resetSubmission(id)
 			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to rejudge submission %d: %w", id, err)
+		}
+		if queued {
+			count++
 		}
 	}
-	if err := s.problemRepo.IncreaseSubmitTotal(submission.ProblemID); err != nil {
-		return err
+	s.wake()
+	return count, nil
+}
+
+// 撤销记录对题目统计的贡献，重置为等待评测并加入评测队列
+func (s *JudgeService) resetSubmission(id model.SubmissionId) (bool, error) {
+	submission, err := s.submissionRepo.GetByID(id)
+	if err != nil {
+		return false, err
+	}
+	// 已在评测中的记录不重复入队
+	if submission.Verdict == model.VerdictPD || submission.Verdict == model.VerdictJD {
+		return false, nil
 	}
-	return nil
+
+	if submission.Verdict == model.VerdictAC {
+		if err := s.problemRepo.DecreaseSubmitCorrect(submission.ProblemID); err != nil {
+			return false, err
+		}
+	}
+	if err := s.problemRepo.DecreaseSubmitTotal(submission.ProblemID); err != nil {
+		return false, err
+	}
+
+	submission.Verdict = model.VerdictPD
+	submission.Score = nil
+	submission.TimeUsed = nil
+	submission.MemoryUsed = nil
+	submission.CompileInfo = nil
+	submission.Subtasks = nil
+	submission.Limits = nil
+	for i := range submission.Testcases {
+		submission.Testcases[i] = model.Testcase{
+			ID:      submission.Testcases[i].ID,
+			Verdict: model.VerdictPD,
+		}
+	}
+	if err := s.submissionRepo.Update(submission); err != nil {
+		return false, err
+	}
+	return true, s.queueRepo.Enqueue(id, true)
+}
+
+// 唯一的评测结果处理协程，逐个保存评测结果，失败时重试
+func (s *JudgeService) consumeResults() {
+	defer close(s.lifecycle.drained)
+
+	for submission := range s.dispatcher.Results() {
+		var err error
+		for attempt := 1; attempt <= saveResultRetries; attempt++ {
+			if err = s.saveResult(submission); err == nil {
+				break
+			}
+			log.Printf("Failed to save result of submission %d (attempt %d/%d): %v", submission.ID, attempt, saveResultRetries, err)
+			if attempt < saveResultRetries {
+				time.Sleep(time.Duration(attempt) * time.Second)
+			}
+		}
+		if err != nil {
+			// 提交仍在队列中，不再续约，租约过期后重新评测
+			log.Printf("Gave up saving result of submission %d, it will be judged again after the lease expires", submission.ID)
+		}
+		s.unhold(submission.ID)
+		s.wake()
+	}
+}
+
+// 比赛或题目不存在、题目不属于比赛等重试也无法解决的榜单更新错误
+func isPermanentRankingError(err error) bool {
+	return errors.Is(err, ErrProblemNotInContest) || errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// 在同一事务中保存评测记录、题目通过情况、比赛榜单与题目统计，并将提交移出队列
+func (s *JudgeService) saveResult(submission *model.Submission) error {
+	submission.ProcessedAt = time.Now()
+
+	return s.submissionRepo.Transaction(func(tx *gorm.DB) error {
+		txs := s.withTx(tx)
+
+		if err := txs.submissionRepo.Update(submission); err != nil {
+			return err
+		}
+
+		// 重测的记录可能改变原有结果，需重新计算而非累加
+		rejudge := false
+		if item, err := txs.queueRepo.GetByID(submission.ID); err == nil {
+			rejudge = item.Rejudge
+		}
+		if rejudge {
+			if err := txs.RecomputeJudgement(submission.ProblemID, submission.UserID); err != nil {
+				return fmt.Errorf("failed to recompute judgement: %w", err)
+			}
+			if submission.ContestID != nil {
+				if err := txs.contestService.RecomputeRanking(*submission.ContestID, submission.UserID); err != nil {
+					if !isPermanentRankingError(err) {
+						return fmt.Errorf("failed to recompute ranking: %w", err)
+					}
+					log.Printf("Ranking of submission %d is not recomputed: %v", submission.ID, err)
+				}
+			}
+		} else {
+			if err := txs.UpdateJudgement(submission); err != nil {
+				return fmt.Errorf("failed to update judgement: %w", err)
+			}
+			if err := txs.contestService.UpdateRanking(submission); err != nil {
+				if !isPermanentRankingError(err) {
+					return fmt.Errorf("failed to update ranking: %w", err)
+				}
+				// 重试也无法更新榜单，仍保存评测结果
+				log.Printf("Ranking of submission %d is not updated: %v", submission.ID, err)
+			}
+		}
+
+		// 更新题目统计信息
+		if submission.Verdict == model.VerdictAC {
+			if err := txs.problemRepo.IncreaseSubmitCorrect(submission.ProblemID); err != nil {
+				return err
+			}
+		}
+		if err := txs.problemRepo.IncreaseSubmitTotal(submission.ProblemID); err != nil {
+			return err
+		}
+		return txs.queueRepo.Delete(submission.ID)
+	})
 }
 
 
 func (s *JudgeService) SubmitCode(req *model.JudgeRequest, userID model.UserId) (*model.SubmissionFull, error) {
+	if s.lifecycle.closed.Load() {
+		return nil, ErrJudgeShuttingDown
+	}
+
 	// 1. 获取题目信息
 	problem, err := s.problemRepo.GetByID(req.Problem)
 	if err != nil {
 		return nil, err
 	}
 
+	// 比赛提交的题目须属于该比赛
+	if req.Contest != nil {
+		if err := s.contestService.CheckContestProblem(*req.Contest, req.Problem); err != nil {
+			return nil, err
+		}
+	}
+
 	// 2. 获取用户信息
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
@@ -170,34 +632,17 @@ func (s *JudgeService) SubmitCode(req *model.JudgeRequest, userID model.UserId)
 	}
 	
 	for i := range submission.Testcases {
-		submission.Testcases[i].ID = i + 1
+		submission.Testcases[i].ID = config.TestCases[i].ID
 		submission.Testcases[i].Verdict = model.VerdictPD
 	}
 
-	// 5. 保存初始提交记录
-	if err := s.submissionRepo.Create(&submission); err != nil {
+	// 5. 保存初始提交记录并加入评测队列，由轮询协程认领评测
+	if err := s.submissionRepo.CreateQueued(&submission); err != nil {
 		return nil, err
 	}
+	s.wake()
 
-	// 6. 准备评测任务
-	task := &model.JudgeTask{
-		Submission: submission,
-		Config: model.JudgeConfig{
-			TimeLimit:   problem.LimitTime,
-			MemoryLimit: problem.LimitMemory,
-			TestCases:   config.TestCases,
-			// 以后可以从题目配置中读取判分器类型
-			CheckerType: "loose",
-		},
-	}
-
-	// 7. 提交评测任务
-	s.dispatcher.Submit(task)
-
-	// 8. 异步处理评测结果
-	go s.processResult()
-
-	// 9. 返回初始响应
+	// 6. 返回初始响应
 	return &model.SubmissionFull{
 		Submission: submission,
 		Problem:    problem.ProblemCore,
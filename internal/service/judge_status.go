@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"reisen-be/internal/model"
+)
+
+// 获取本评测实例与评测队列的运行状态
+func (s *JudgeService) Status() (*model.JudgeStatus, error) {
+	total, waiting, owned, err := s.queueRepo.Stats(s.instance)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := s.dispatcher.Stats()
+	status := &model.JudgeStatus{
+		Instance: s.instance,
+		Workers:  stats.Workers,
+		Busy:     stats.Busy,
+		InFlight: stats.Pending,
+		Queue: model.JudgeQueueStatus{
+			Total:   total,
+			Waiting: waiting,
+			Owned:   owned,
+		},
+		Sandboxes: []model.SandboxStatus{},
+		Verdicts:  s.metrics.Verdicts(),
+	}
+	for _, sandbox := range s.sandboxes.Stats() {
+		status.Sandboxes = append(status.Sandboxes, model.SandboxStatus(sandbox))
+	}
+	return status, nil
+}
+
+// 以 Prometheus 文本格式输出评测相关的指标
+func (s *JudgeService) WriteMetrics(w io.Writer) error {
+	status, err := s.Status()
+	if err != nil {
+		return err
+	}
+
+	gauge := func(name, help string, value any) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", name, help, name, name, value)
+	}
+	gauge("reisen_judge_queue_depth", "Submissions in the judge queue.", status.Queue.Total)
+	gauge("reisen_judge_queue_waiting", "Submissions waiting to be claimed.", status.Queue.Waiting)
+	gauge("reisen_judge_workers", "Judge workers of this instance.", status.Workers)
+	gauge("reisen_judge_workers_busy", "Judge workers currently judging.", status.Busy)
+	gauge("reisen_judge_in_flight", "Claimed submissions without a result yet.", status.InFlight)
+
+	fmt.Fprintln(w, "# HELP reisen_sandbox_up Whether the sandbox backend passed its last health check.")
+	fmt.Fprintln(w, "# TYPE reisen_sandbox_up gauge")
+	for _, sandbox := range status.Sandboxes {
+		up := 0
+		if sandbox.Healthy {
+			up = 1
+		}
+		fmt.Fprintf(w, "reisen_sandbox_up{sandbox=%q} %d\n", sandbox.Name, up)
+	}
+	fmt.Fprintln(w, "# HELP reisen_sandbox_running Requests running on the sandbox backend.")
+	fmt.Fprintln(w, "# TYPE reisen_sandbox_running gauge")
+	for _, sandbox := range status.Sandboxes {
+		fmt.Fprintf(w, "reisen_sandbox_running{sandbox=%q} %d\n", sandbox.Name, sandbox.Running)
+	}
+	fmt.Fprintln(w, "# HELP reisen_sandbox_waiting Requests waiting for a slot on the sandbox backend.")
+	fmt.Fprintln(w, "# TYPE reisen_sandbox_waiting gauge")
+	for _, sandbox := range status.Sandboxes {
+		fmt.Fprintf(w, "reisen_sandbox_waiting{sandbox=%q} %d\n", sandbox.Name, sandbox.Waiting)
+	}
+	fmt.Fprintln(w, "# HELP reisen_sandbox_requests_total Run requests sent to the sandbox backend.")
+	fmt.Fprintln(w, "# TYPE reisen_sandbox_requests_total counter")
+	for _, sandbox := range status.Sandboxes {
+		fmt.Fprintf(w, "reisen_sandbox_requests_total{sandbox=%q} %d\n", sandbox.Name, sandbox.Requests)
+	}
+	fmt.Fprintln(w, "# HELP reisen_sandbox_errors_total Failed run requests to the sandbox backend.")
+	fmt.Fprintln(w, "# TYPE reisen_sandbox_errors_total counter")
+	for _, sandbox := range status.Sandboxes {
+		fmt.Fprintf(w, "reisen_sandbox_errors_total{sandbox=%q} %d\n", sandbox.Name, sandbox.Errors)
+	}
+
+	s.metrics.WritePrometheus(w)
+	return nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reisen-be/internal/model"
+	"reisen-be/internal/repository"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 单个验题程序评测的最长时间
+const juryJudgeTimeout = 10 * time.Minute
+
+var ErrJuryVerificationRunning = errors.New("jury solutions of this problem are being verified")
+
+// 验题服务：用当前测试数据评测出题人上传的程序并检查结果是否符合预期。
+// 评测结果只保存在验题记录中，不影响提交记录、通过情况与题目统计
+type JurySolutionService struct {
+	jurySolutionRepo *repository.JurySolutionRepository
+	problemRepo      *repository.ProblemRepository
+	judgeService     *JudgeService
+
+	verifying map[model.ProblemId]bool // 正在验证的题目
+	mu        sync.Mutex
+}
+
+func NewJurySolutionService(
+	jurySolutionRepo *repository.JurySolutionRepository,
+	problemRepo *repository.ProblemRepository,
+	judgeService *JudgeService,
+) *JurySolutionService {
+	// 上次运行中断的验证不会继续，恢复其状态
+	if count, err := jurySolutionRepo.ResetUnfinished(); err != nil {
+		log.Printf("Failed to reset unfinished jury verifications: %v", err)
+	} else if count > 0 {
+		log.Printf("Reset %d unfinished jury verification(s)", count)
+	}
+
+	return &JurySolutionService{
+		jurySolutionRepo: jurySolutionRepo,
+		problemRepo:      problemRepo,
+		judgeService:     judgeService,
+		verifying:        make(map[model.ProblemId]bool),
+	}
+}
+
+// 创建或更新验题程序，更新后需重新验证
+func (s *JurySolutionService) Save(solution *model.JurySolution) error {
+	if err := validateExpectations(solution); err != nil {
+		return err
+	}
+	if solution.ID == 0 {
+		if _, err := s.problemRepo.GetByID(solution.ProblemID); err != nil {
+			return err
+		}
+		return s.jurySolutionRepo.Create(solution)
+	}
+
+	// 不允许移动到其他题目
+	existing, err := s.jurySolutionRepo.GetByID(solution.ID)
+	if err != nil {
+		return err
+	}
+	solution.ProblemID = existing.ProblemID
+	return s.jurySolutionRepo.Update(solution)
+}
+
+func (s *JurySolutionService) Delete(id model.JurySolutionId) error {
+	return s.jurySolutionRepo.Delete(id)
+}
+
+func (s *JurySolutionService) List(problemID model.ProblemId) ([]model.JurySolution, error) {
+	return s.jurySolutionRepo.ListByProblem(problemID)
+}
+
+// 开始验证题目的全部验题程序，返回程序数量，结果通过 List 查看
+func (s *JurySolutionService) Verify(problemID model.ProblemId) (int, error) {
+	solutions, err := s.jurySolutionRepo.ListByProblem(problemID)
+	if err != nil {
+		return 0, err
+	}
+	if len(solutions) == 0 {
+		return 0, nil
+	}
+	if s.judgeService.lifecycle.closed.Load() {
+		return 0, ErrJudgeShuttingDown
+	}
+
+	// 记录验证所用的测试数据与评测配置，之后任一变化都需重新验证
+	problem, err := s.problemRepo.GetByID(problemID)
+	if err != nil {
+		return 0, err
+	}
+	fingerprint, err := s.judgeService.problemFilesystem.GetTestdataFingerprint(problem.ProblemCore)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	if s.verifying[problemID] {
+		s.mu.Unlock()
+		return 0, ErrJuryVerificationRunning
+	}
+	s.verifying[problemID] = true
+	s.mu.Unlock()
+
+	for i := range solutions {
+		if err := s.jurySolutionRepo.UpdateStatus(solutions[i].ID, model.JurySolutionStatusPending); err != nil {
+			s.finish(problemID)
+			return 0, err
+		}
+	}
+
+	go func() {
+		defer s.finish(problemID)
+		for i := range solutions {
+			if s.judgeService.lifecycle.closed.Load() {
+				return
+			}
+			solutions[i].Fingerprint = fingerprint
+			if err := s.verify(&solutions[i]); err != nil {
+				log.Printf("Failed to verify jury solution %d: %v", solutions[i].ID, err)
+			}
+		}
+	}()
+	return len(solutions), nil
+}
+
+func (s *JurySolutionService) finish(problemID model.ProblemId) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.verifying, problemID)
+}
+
+// 评测单个验题程序并保存结果
+func (s *JurySolutionService) verify(solution *model.JurySolution) error {
+	if err := s.jurySolutionRepo.UpdateStatus(solution.ID, model.JurySolutionStatusJudging); err != nil {
+		return err
+	}
+
+	task, err := s.judgeService.newJudgeTask(&model.Submission{
+		SubmissionCore: model.SubmissionCore{
+			ProblemID:  solution.ProblemID,
+			Lang:       solution.Lang,
+			CodeLength: len(solution.Code),
+		},
+		Code: solution.Code,
+	})
+	if err != nil {
+		message := err.Error()
+		verdict := model.VerdictUKE
+		solution.Verdict = &verdict
+		solution.CompileInfo = &model.CompileInfo{Success: false, Message: message}
+		return s.saveResult(solution, nil)
+	}
+	task.Silent = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), juryJudgeTimeout)
+	defer cancel()
+	s.judgeService.dispatcher.Judge(ctx, task)
+
+	solution.Verdict = &task.Verdict
+	solution.Score = task.Score
+	solution.TimeUsed = task.TimeUsed
+	solution.MemoryUsed = task.MemoryUsed
+	solution.CompileInfo = task.CompileInfo
+	solution.Testcases = task.Testcases
+	solution.Subtasks = task.Subtasks
+	return s.saveResult(solution, task.Subtasks)
+}
+
+func (s *JurySolutionService) saveResult(solution *model.JurySolution, subtasks model.SubtaskList) error {
+	mismatches := checkExpectations(solution, subtasks)
+	matched := len(mismatches) == 0
+	now := time.Now()
+
+	solution.Status = model.JurySolutionStatusDone
+	solution.Matched = &matched
+	solution.Mismatches = mismatches
+	solution.JudgedAt = &now
+	return s.jurySolutionRepo.SaveResult(solution)
+}
+
+// 检查预期结果的写法
+func validateExpectations(solution *model.JurySolution) error {
+	if len(solution.Expected) == 0 {
+		return errors.New("expected verdict is required")
+	}
+	check := func(verdicts model.VerdictList) error {
+		for _, verdict := range verdicts {
+			switch verdict {
+			case model.VerdictAC, model.VerdictWA, model.VerdictRE, model.VerdictTLE, model.VerdictMLE,
+				model.VerdictOLE, model.VerdictCE, model.VerdictPE, model.VerdictPC:
+			default:
+				return fmt.Errorf("invalid expected verdict %q", verdict)
+			}
+		}
+		return nil
+	}
+	if err := check(solution.Expected); err != nil {
+		return err
+	}
+	for _, verdicts := range solution.ExpectedSubtasks {
+		if err := check(verdicts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 比较评测结果与预期，返回不符合之处
+func checkExpectations(solution *model.JurySolution, subtasks model.SubtaskList) model.StringList {
+	mismatches := model.StringList{}
+	verdict := model.VerdictUKE
+	if solution.Verdict != nil {
+		verdict = *solution.Verdict
+	}
+	if !solution.Expected.Contains(verdict) {
+		mismatches = append(mismatches, fmt.Sprintf("expected %v, got %s", solution.Expected, verdict))
+	}
+
+	results := make(map[int]model.VerdictId, len(subtasks))
+	for _, subtask := range subtasks {
+		results[subtask.ID] = subtask.Verdict
+	}
+	ids := make([]int, 0, len(solution.ExpectedSubtasks))
+	for id := range solution.ExpectedSubtasks {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		expected := solution.ExpectedSubtasks[id]
+		result, ok := results[id]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("subtask %d: not found", id))
+		} else if !expected.Contains(result) {
+			mismatches = append(mismatches, fmt.Sprintf("subtask %d: expected %v, got %s", id, expected, result))
+		}
+	}
+	return mismatches
+}
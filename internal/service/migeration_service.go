@@ -2,6 +2,8 @@
 package service
 
 import (
+	"reisen-be/internal/model"
+
 	"gorm.io/gorm"
 )
 
@@ -14,7 +16,18 @@ func NewGormMigrationService(db *gorm.DB) *GormMigrationService {
 }
 
 func (s *GormMigrationService) RunMigrations() error {
-	
-
-	return nil
+	// 评测记录新增子任务详情与时空限制
+	if err := s.db.AutoMigrate(&model.Submission{}); err != nil {
+		return err
+	}
+	// 持久化评测队列
+	if err := s.db.AutoMigrate(&model.JudgeQueueItem{}); err != nil {
+		return err
+	}
+	// 题目新增测试数据校验状态
+	if err := s.db.AutoMigrate(&model.Problem{}); err != nil {
+		return err
+	}
+	// 验题程序
+	return s.db.AutoMigrate(&model.JurySolution{})
 }
\ No newline at end of file
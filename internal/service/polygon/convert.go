@@ -0,0 +1,207 @@
+package polygon
+
+import (
+	"math"
+	"reisen-be/internal/model"
+	"strings"
+)
+
+// Polygon 题面语言与本站题面语言代码的对应关系，未列出的语言原样保留
+var languageCodes = map[string]string{
+	"english":  "en-US",
+	"chinese":  "zh-CN",
+	"russian":  "ru-RU",
+	"japanese": "ja-JP",
+	"korean":   "ko-KR",
+}
+
+// 未列出的语言原样返回，并返回 false
+func LanguageCode(language string) (string, bool) {
+	if code, ok := languageCodes[language]; ok {
+		return code, true
+	}
+	return language, false
+}
+
+func PolygonLanguage(code string) string {
+	for language, c := range languageCodes {
+		if c == code {
+			return language
+		}
+	}
+	return code
+}
+
+// 由 Polygon 源文件类型（如 "cpp.g++17"）得到编程语言，无法识别时返回空
+func LangFromType(sourceType string) model.CodeLangId {
+	switch {
+	case strings.HasPrefix(sourceType, "cpp."):
+		switch {
+		case strings.Contains(sourceType, "20"), strings.Contains(sourceType, "23"):
+			return "cpp20"
+		case strings.Contains(sourceType, "17"):
+			return "cpp17"
+		case strings.Contains(sourceType, "11"):
+			return "cpp11"
+		default:
+			return "cpp14"
+		}
+	case strings.HasPrefix(sourceType, "c."):
+		return "c"
+	case strings.HasPrefix(sourceType, "java"):
+		return "java"
+	case strings.HasPrefix(sourceType, "python.3"), strings.HasPrefix(sourceType, "python.pypy3"):
+		return "python"
+	case strings.HasPrefix(sourceType, "go"):
+		return "go"
+	case strings.HasPrefix(sourceType, "rust"):
+		return "rust"
+	case strings.HasPrefix(sourceType, "pas."), strings.HasPrefix(sourceType, "delphi"):
+		return "pas"
+	}
+	return ""
+}
+
+// 由编程语言得到 Polygon 源文件类型
+func TypeFromLang(lang model.CodeLangId) string {
+	switch lang {
+	case "c":
+		return "c.gcc"
+	case "cpp98", "cpp11":
+		return "cpp.g++11"
+	case "cpp14", "":
+		return "cpp.g++14"
+	case "cpp17":
+		return "cpp.g++17"
+	case "cpp20":
+		return "cpp.g++20"
+	case "java":
+		return "java21"
+	case "python":
+		return "python.3"
+	case "go":
+		return "go"
+	case "rust":
+		return "rust"
+	case "pas":
+		return "pas.fpc"
+	}
+	return string(lang)
+}
+
+// 导出源文件时使用的扩展名
+func SourceExt(lang model.CodeLangId) string {
+	switch lang {
+	case "c":
+		return ".c"
+	case "java":
+		return ".java"
+	case "python":
+		return ".py"
+	case "go":
+		return ".go"
+	case "rust":
+		return ".rs"
+	case "pas":
+		return ".pas"
+	}
+	return ".cpp"
+}
+
+var rejectedVerdicts = model.VerdictList{
+	model.VerdictWA, model.VerdictPE, model.VerdictRE, model.VerdictTLE, model.VerdictMLE, model.VerdictOLE,
+}
+
+// 由 Polygon 解答标签得到可接受的评测结果，无法验证的标签返回 false
+func ExpectedVerdicts(tag string) (model.VerdictList, bool) {
+	switch tag {
+	case "main", "accepted":
+		return model.VerdictList{model.VerdictAC}, true
+	case "wrong-answer":
+		return model.VerdictList{model.VerdictWA}, true
+	case "presentation-error":
+		return model.VerdictList{model.VerdictPE}, true
+	case "time-limit-exceeded":
+		return model.VerdictList{model.VerdictTLE}, true
+	case "time-limit-exceeded-or-accepted":
+		return model.VerdictList{model.VerdictTLE, model.VerdictAC}, true
+	case "time-limit-exceeded-or-memory-limit-exceeded":
+		return model.VerdictList{model.VerdictTLE, model.VerdictMLE}, true
+	case "memory-limit-exceeded":
+		return model.VerdictList{model.VerdictMLE}, true
+	case "rejected":
+		return rejectedVerdicts, true
+	}
+	return nil, false
+}
+
+// 由可接受的评测结果得到 Polygon 解答标签
+func SolutionTag(expected model.VerdictList) string {
+	has := func(verdicts ...model.VerdictId) bool {
+		if len(expected) != len(verdicts) {
+			return false
+		}
+		for _, verdict := range verdicts {
+			if !expected.Contains(verdict) {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case has(model.VerdictAC):
+		return "accepted"
+	case has(model.VerdictWA):
+		return "wrong-answer"
+	case has(model.VerdictPE):
+		return "presentation-error"
+	case has(model.VerdictTLE):
+		return "time-limit-exceeded"
+	case has(model.VerdictTLE, model.VerdictAC):
+		return "time-limit-exceeded-or-accepted"
+	case has(model.VerdictTLE, model.VerdictMLE):
+		return "time-limit-exceeded-or-memory-limit-exceeded"
+	case has(model.VerdictMLE):
+		return "memory-limit-exceeded"
+	}
+	return "rejected"
+}
+
+// 可由内置判分器代替的 testlib 标准判分器
+func BuiltinChecker(name string) (model.JudgeConfig, bool) {
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "std::"), ".cpp")
+	switch name {
+	case "wcmp", "ncmp":
+		return model.JudgeConfig{CheckerType: "token"}, true
+	case "fcmp":
+		return model.JudgeConfig{CheckerType: "strict"}, true
+	case "rcmp4":
+		return model.JudgeConfig{CheckerType: "float", Epsilon: 1e-4, RelativeEpsilon: 1e-4}, true
+	case "rcmp6":
+		return model.JudgeConfig{CheckerType: "float", Epsilon: 1e-6, RelativeEpsilon: 1e-6}, true
+	case "rcmp9":
+		return model.JudgeConfig{CheckerType: "float", Epsilon: 1e-9, RelativeEpsilon: 1e-9}, true
+	}
+	return model.JudgeConfig{}, false
+}
+
+// 内置判分器对应的 testlib 标准判分器名，没有对应时（如 icase、unordered）返回空
+func StdCheckerName(config model.JudgeConfig) string {
+	switch config.CheckerType {
+	case "token":
+		return "std::wcmp.cpp"
+	case "strict", "loose", "":
+		return "std::fcmp.cpp"
+	case "float":
+		epsilon := math.Max(config.Epsilon, config.RelativeEpsilon)
+		switch {
+		case epsilon >= 1e-4:
+			return "std::rcmp4.cpp"
+		case epsilon >= 1e-6:
+			return "std::rcmp6.cpp"
+		default:
+			return "std::rcmp9.cpp"
+		}
+	}
+	return ""
+}
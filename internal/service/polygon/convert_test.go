@@ -0,0 +1,139 @@
+package polygon
+
+import (
+	"reisen-be/internal/model"
+	"slices"
+	"testing"
+)
+
+func TestBuiltinChecker(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     model.JudgeConfig
+		wantOk   bool
+		wantBack string // 导出时对应的标准判分器
+	}{
+		{"std::wcmp.cpp", model.JudgeConfig{CheckerType: "token"}, true, "std::wcmp.cpp"},
+		{"std::ncmp.cpp", model.JudgeConfig{CheckerType: "token"}, true, "std::wcmp.cpp"},
+		{"std::fcmp.cpp", model.JudgeConfig{CheckerType: "strict"}, true, "std::fcmp.cpp"},
+		{"fcmp", model.JudgeConfig{CheckerType: "strict"}, true, "std::fcmp.cpp"},
+		{"std::rcmp4.cpp", model.JudgeConfig{CheckerType: "float", Epsilon: 1e-4, RelativeEpsilon: 1e-4}, true, "std::rcmp4.cpp"},
+		{"std::rcmp6.cpp", model.JudgeConfig{CheckerType: "float", Epsilon: 1e-6, RelativeEpsilon: 1e-6}, true, "std::rcmp6.cpp"},
+		{"std::rcmp9.cpp", model.JudgeConfig{CheckerType: "float", Epsilon: 1e-9, RelativeEpsilon: 1e-9}, true, "std::rcmp9.cpp"},
+		{"std::yesno.cpp", model.JudgeConfig{}, false, ""},
+		{"check.cpp", model.JudgeConfig{}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := BuiltinChecker(tt.name)
+			if ok != tt.wantOk || got.CheckerType != tt.want.CheckerType || got.Epsilon != tt.want.Epsilon || got.RelativeEpsilon != tt.want.RelativeEpsilon {
+				t.Fatalf("got %+v %v, want %+v %v", got, ok, tt.want, tt.wantOk)
+			}
+			if ok {
+				if back := StdCheckerName(got); back != tt.wantBack {
+					t.Fatalf("exported as %q, want %q", back, tt.wantBack)
+				}
+			}
+		})
+	}
+}
+
+func TestStdCheckerName(t *testing.T) {
+	tests := []struct {
+		config model.JudgeConfig
+		want   string
+	}{
+		{model.JudgeConfig{}, "std::fcmp.cpp"},
+		{model.JudgeConfig{CheckerType: "loose"}, "std::fcmp.cpp"},
+		{model.JudgeConfig{CheckerType: "float", Epsilon: 1e-3}, "std::rcmp4.cpp"},
+		{model.JudgeConfig{CheckerType: "float", Epsilon: 1e-7}, "std::rcmp9.cpp"},
+		{model.JudgeConfig{CheckerType: "icase"}, ""},
+		{model.JudgeConfig{CheckerType: "unordered"}, ""},
+		{model.JudgeConfig{CheckerType: "custom"}, ""},
+	}
+	for _, tt := range tests {
+		if got := StdCheckerName(tt.config); got != tt.want {
+			t.Errorf("StdCheckerName(%+v) = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestExpectedVerdicts(t *testing.T) {
+	tests := []struct {
+		tag    string
+		want   model.VerdictList
+		wantOk bool
+	}{
+		{"main", model.VerdictList{model.VerdictAC}, true},
+		{"accepted", model.VerdictList{model.VerdictAC}, true},
+		{"wrong-answer", model.VerdictList{model.VerdictWA}, true},
+		{"presentation-error", model.VerdictList{model.VerdictPE}, true},
+		{"time-limit-exceeded", model.VerdictList{model.VerdictTLE}, true},
+		{"time-limit-exceeded-or-accepted", model.VerdictList{model.VerdictTLE, model.VerdictAC}, true},
+		{"time-limit-exceeded-or-memory-limit-exceeded", model.VerdictList{model.VerdictTLE, model.VerdictMLE}, true},
+		{"memory-limit-exceeded", model.VerdictList{model.VerdictMLE}, true},
+		{"rejected", rejectedVerdicts, true},
+		{"failed", nil, false},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, ok := ExpectedVerdicts(tt.tag)
+			if ok != tt.wantOk || !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v %v, want %v %v", got, ok, tt.want, tt.wantOk)
+			}
+			// 导出后再导入得到相同的预期结果
+			if ok && tt.tag != "main" {
+				if tag := SolutionTag(got); tag != tt.tag {
+					t.Fatalf("exported as %q, want %q", tag, tt.tag)
+				}
+			}
+		})
+	}
+}
+
+func TestLanguageCode(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+		wantOk   bool
+	}{
+		{"english", "en-US", true},
+		{"chinese", "zh-CN", true},
+		{"russian", "ru-RU", true},
+		{"ukrainian", "ukrainian", false},
+	}
+	for _, tt := range tests {
+		code, ok := LanguageCode(tt.language)
+		if code != tt.want || ok != tt.wantOk {
+			t.Errorf("LanguageCode(%q) = %q %v, want %q %v", tt.language, code, ok, tt.want, tt.wantOk)
+		}
+		if ok && PolygonLanguage(code) != tt.language {
+			t.Errorf("PolygonLanguage(%q) = %q, want %q", code, PolygonLanguage(code), tt.language)
+		}
+	}
+}
+
+func TestLangFromType(t *testing.T) {
+	tests := []struct {
+		sourceType string
+		want       model.CodeLangId
+	}{
+		{"cpp.g++17", "cpp17"},
+		{"cpp.gcc14-64-msys2-g++23", "cpp20"},
+		{"cpp.g++11", "cpp11"},
+		{"cpp.g++", "cpp14"},
+		{"c.gcc", "c"},
+		{"java21", "java"},
+		{"python.3", "python"},
+		{"python.pypy3", "python"},
+		{"python.2", ""},
+		{"pas.fpc", "pas"},
+		{"kotlin", ""},
+	}
+	for _, tt := range tests {
+		if got := LangFromType(tt.sourceType); got != tt.want {
+			t.Errorf("LangFromType(%q) = %q, want %q", tt.sourceType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package polygon
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Polygon 题目包的 problem.xml，只包含导入导出需要的部分
+type Problem struct {
+	XMLName    xml.Name    `xml:"problem"`
+	Revision   int         `xml:"revision,attr,omitempty"`
+	ShortName  string      `xml:"short-name,attr"`
+	URL        string      `xml:"url,attr,omitempty"`
+	Names      []Name      `xml:"names>name"`
+	Statements []Statement `xml:"statements>statement"`
+	Judging    Judging     `xml:"judging"`
+	Files      Files       `xml:"files"`
+	Assets     Assets      `xml:"assets"`
+}
+
+type Name struct {
+	Language string `xml:"language,attr"`
+	Value    string `xml:"value,attr"`
+}
+
+type Statement struct {
+	Charset  string `xml:"charset,attr,omitempty"`
+	Language string `xml:"language,attr"`
+	Mathjax  bool   `xml:"mathjax,attr,omitempty"`
+	Path     string `xml:"path,attr"`
+	Type     string `xml:"type,attr"`
+}
+
+type Judging struct {
+	InputFile  string    `xml:"input-file,attr"`
+	OutputFile string    `xml:"output-file,attr"`
+	Testsets   []Testset `xml:"testset"`
+}
+
+type Testset struct {
+	Name              string  `xml:"name,attr"`
+	TimeLimit         int     `xml:"time-limit"`   // ms
+	MemoryLimit       int64   `xml:"memory-limit"` // 字节
+	TestCount         int     `xml:"test-count"`
+	InputPathPattern  string  `xml:"input-path-pattern"`
+	AnswerPathPattern string  `xml:"answer-path-pattern"`
+	Tests             []Test  `xml:"tests>test"`
+	Groups            []Group `xml:"groups>group,omitempty"`
+}
+
+type Test struct {
+	Method string  `xml:"method,attr"` // "manual" 或 "generated"
+	Cmd    string  `xml:"cmd,attr,omitempty"`
+	Sample bool    `xml:"sample,attr,omitempty"`
+	Points float64 `xml:"points,attr,omitempty"`
+	Group  string  `xml:"group,attr,omitempty"`
+}
+
+type Group struct {
+	Name           string       `xml:"name,attr"`
+	Points         float64      `xml:"points,attr,omitempty"`
+	PointsPolicy   string       `xml:"points-policy,attr,omitempty"` // "complete-group" 或 "each-test"
+	FeedbackPolicy string       `xml:"feedback-policy,attr,omitempty"`
+	Dependencies   []Dependency `xml:"dependencies>dependency,omitempty"`
+}
+
+type Dependency struct {
+	Group string `xml:"group,attr"`
+}
+
+type Files struct {
+	Resources   []File       `xml:"resources>file"`
+	Executables []Executable `xml:"executables>executable"`
+}
+
+type File struct {
+	Path string `xml:"path,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type Executable struct {
+	Source File `xml:"source"`
+}
+
+type Assets struct {
+	Checker    *Checker   `xml:"checker,omitempty"`
+	Interactor *Program   `xml:"interactor,omitempty"`
+	Validators []Program  `xml:"validators>validator,omitempty"`
+	Solutions  []Solution `xml:"solutions>solution"`
+}
+
+type Checker struct {
+	Name   string `xml:"name,attr,omitempty"` // 标准判分器形如 "std::wcmp.cpp"
+	Type   string `xml:"type,attr"`
+	Source *File  `xml:"source,omitempty"` // 导出内置判分器时没有源文件
+}
+
+type Program struct {
+	Source File `xml:"source"`
+}
+
+type Solution struct {
+	Tag    string `xml:"tag,attr"`
+	Source File   `xml:"source"`
+}
+
+// 题面目录下的 problem-properties.json
+type StatementProperties struct {
+	Name        string       `json:"name"`
+	Legend      string       `json:"legend"`
+	Input       string       `json:"input"`
+	Output      string       `json:"output"`
+	Interaction string       `json:"interaction,omitempty"`
+	Notes       string       `json:"notes"`
+	Scoring     string       `json:"scoring,omitempty"`
+	InputFile   string       `json:"inputFile"`
+	OutputFile  string       `json:"outputFile"`
+	TimeLimit   int          `json:"timeLimit"`
+	MemoryLimit int64        `json:"memoryLimit"`
+	SampleTests []SampleTest `json:"sampleTests"`
+}
+
+type SampleTest struct {
+	Input      string `json:"input"`
+	Output     string `json:"output"`
+	InputFile  string `json:"inputFile"`
+	OutputFile string `json:"outputFile"`
+}
+
+func Parse(data []byte) (*Problem, error) {
+	var problem Problem
+	if err := xml.Unmarshal(data, &problem); err != nil {
+		return nil, fmt.Errorf("failed to parse problem.xml: %v", err)
+	}
+	return &problem, nil
+}
+
+func (p *Problem) Marshal() ([]byte, error) {
+	data, err := xml.MarshalIndent(p, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// 评测使用的测试点集合，优先使用名为 tests 的集合
+func (p *Problem) MainTestset() *Testset {
+	for i := range p.Judging.Testsets {
+		if p.Judging.Testsets[i].Name == "tests" {
+			return &p.Judging.Testsets[i]
+		}
+	}
+	if len(p.Judging.Testsets) > 0 {
+		return &p.Judging.Testsets[0]
+	}
+	return nil
+}
+
+// 按路径模板（如 "tests/%02d"）得到第 index 个测试点的路径，index 从 1 开始
+func FormatPath(pattern string, index int) string {
+	if strings.Contains(pattern, "%") {
+		return fmt.Sprintf(pattern, index)
+	}
+	return pattern
+}
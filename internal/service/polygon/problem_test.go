@@ -0,0 +1,202 @@
+package polygon
+
+import (
+	"reflect"
+	"testing"
+)
+
+const sampleProblemXML = `<?xml version="1.0" encoding="utf-8" standalone="no"?>
+<problem revision="12" short-name="a-plus-b" url="https://polygon.codeforces.com/p/user/a-plus-b">
+    <names>
+        <name language="english" value="A+B"/>
+        <name language="russian" value="А+Б"/>
+    </names>
+    <statements>
+        <statement charset="UTF-8" language="english" mathjax="true" path="statements/english/problem.tex" type="application/x-tex"/>
+    </statements>
+    <judging cpu-name="Intel(R) Core(TM) i3-8100 CPU @ 3.60GHz" input-file="" output-file="">
+        <testset name="pretests">
+            <time-limit>1000</time-limit>
+            <memory-limit>268435456</memory-limit>
+            <test-count>0</test-count>
+            <input-path-pattern>pretests/%02d</input-path-pattern>
+            <answer-path-pattern>pretests/%02d.a</answer-path-pattern>
+            <tests/>
+        </testset>
+        <testset name="tests">
+            <time-limit>2000</time-limit>
+            <memory-limit>536870912</memory-limit>
+            <test-count>3</test-count>
+            <input-path-pattern>tests/%02d</input-path-pattern>
+            <answer-path-pattern>tests/%02d.a</answer-path-pattern>
+            <tests>
+                <test method="manual" sample="true" points="0" group="0"/>
+                <test cmd="gen 1 10" method="generated" points="40" group="1"/>
+                <test cmd="gen 2 10" method="generated" points="60" group="1"/>
+            </tests>
+            <groups>
+                <group name="0" points-policy="each-test"/>
+                <group feedback-policy="points" name="1" points="100" points-policy="complete-group">
+                    <dependencies>
+                        <dependency group="0"/>
+                    </dependencies>
+                </group>
+            </groups>
+        </testset>
+    </judging>
+    <files>
+        <resources>
+            <file path="files/testlib.h" type="h.g++"/>
+        </resources>
+        <executables>
+            <executable>
+                <source path="files/gen.cpp" type="cpp.g++17"/>
+            </executable>
+        </executables>
+    </files>
+    <assets>
+        <checker name="std::ncmp.cpp" type="testlib">
+            <source path="files/check.cpp" type="cpp.g++17"/>
+        </checker>
+        <validators>
+            <validator>
+                <source path="files/val.cpp" type="cpp.g++17"/>
+            </validator>
+        </validators>
+        <solutions>
+            <solution tag="main">
+                <source path="solutions/main.cpp" type="cpp.g++17"/>
+            </solution>
+            <solution tag="wrong-answer">
+                <source path="solutions/wa.py" type="python.3"/>
+            </solution>
+        </solutions>
+    </assets>
+</problem>
+`
+
+func TestParse(t *testing.T) {
+	problem, err := Parse([]byte(sampleProblemXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if problem.ShortName != "a-plus-b" || problem.Revision != 12 {
+		t.Errorf("got short name %q revision %d", problem.ShortName, problem.Revision)
+	}
+	wantNames := []Name{{Language: "english", Value: "A+B"}, {Language: "russian", Value: "А+Б"}}
+	if !reflect.DeepEqual(problem.Names, wantNames) {
+		t.Errorf("got names %+v, want %+v", problem.Names, wantNames)
+	}
+
+	testset := problem.MainTestset()
+	if testset == nil || testset.Name != "tests" {
+		t.Fatalf("got main testset %+v, want tests", testset)
+	}
+	if testset.TimeLimit != 2000 || testset.MemoryLimit != 512<<20 {
+		t.Errorf("got limits %d ms %d bytes", testset.TimeLimit, testset.MemoryLimit)
+	}
+	wantTests := []Test{
+		{Method: "manual", Sample: true, Group: "0"},
+		{Method: "generated", Cmd: "gen 1 10", Points: 40, Group: "1"},
+		{Method: "generated", Cmd: "gen 2 10", Points: 60, Group: "1"},
+	}
+	if !reflect.DeepEqual(testset.Tests, wantTests) {
+		t.Errorf("got tests %+v, want %+v", testset.Tests, wantTests)
+	}
+	wantGroups := []Group{
+		{Name: "0", PointsPolicy: "each-test"},
+		{Name: "1", Points: 100, PointsPolicy: "complete-group", FeedbackPolicy: "points", Dependencies: []Dependency{{Group: "0"}}},
+	}
+	if !reflect.DeepEqual(testset.Groups, wantGroups) {
+		t.Errorf("got groups %+v, want %+v", testset.Groups, wantGroups)
+	}
+
+	if len(problem.Files.Executables) != 1 || problem.Files.Executables[0].Source.Path != "files/gen.cpp" {
+		t.Errorf("got executables %+v", problem.Files.Executables)
+	}
+	checker := problem.Assets.Checker
+	if checker == nil || checker.Name != "std::ncmp.cpp" || checker.Source == nil || checker.Source.Path != "files/check.cpp" {
+		t.Errorf("got checker %+v", checker)
+	}
+	if len(problem.Assets.Validators) != 1 || problem.Assets.Validators[0].Source.Path != "files/val.cpp" {
+		t.Errorf("got validators %+v", problem.Assets.Validators)
+	}
+	wantSolutions := []Solution{
+		{Tag: "main", Source: File{Path: "solutions/main.cpp", Type: "cpp.g++17"}},
+		{Tag: "wrong-answer", Source: File{Path: "solutions/wa.py", Type: "python.3"}},
+	}
+	if !reflect.DeepEqual(problem.Assets.Solutions, wantSolutions) {
+		t.Errorf("got solutions %+v, want %+v", problem.Assets.Solutions, wantSolutions)
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	problem, err := Parse([]byte(sampleProblemXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := problem.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, problem) {
+		t.Fatalf("got %+v after round trip, want %+v", parsed, problem)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse([]byte("<problem><names>")); err == nil {
+		t.Fatal("parsed malformed problem.xml")
+	}
+}
+
+func TestMainTestset(t *testing.T) {
+	tests := []struct {
+		name     string
+		testsets []string
+		want     string
+	}{
+		{"prefers tests", []string{"pretests", "tests"}, "tests"},
+		{"falls back to the first", []string{"pretests", "extra"}, "pretests"},
+		{"no testset", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := &Problem{}
+			for _, name := range tt.testsets {
+				problem.Judging.Testsets = append(problem.Judging.Testsets, Testset{Name: name})
+			}
+			got := ""
+			if testset := problem.MainTestset(); testset != nil {
+				got = testset.Name
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		index   int
+		want    string
+	}{
+		{"tests/%02d", 3, "tests/03"},
+		{"tests/%02d", 123, "tests/123"},
+		{"tests/%02d.a", 7, "tests/07.a"},
+		{"tests/%d", 7, "tests/7"},
+		{"tests/input", 7, "tests/input"},
+	}
+	for _, tt := range tests {
+		if got := FormatPath(tt.pattern, tt.index); got != tt.want {
+			t.Errorf("FormatPath(%q, %d) = %q, want %q", tt.pattern, tt.index, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reisen-be/internal/model"
+	"reisen-be/internal/service/judge"
+	"strings"
+)
+
+// 生成脚本中的一条命令："<生成器> [参数...] > <文件名>"
+type generatorCommand struct {
+	line      int
+	text      string
+	generator string
+	args      []string
+	output    string
+}
+
+// 解析生成脚本，空行与 # 开头的行被忽略
+func parseGeneratorScript(config *model.GeneratorConfig) ([]generatorCommand, error) {
+	var commands []generatorCommand
+	outputs := map[string]int{}
+
+	for i, line := range strings.Split(config.Script, "\n") {
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.Split(text, ">")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<generator> [args...] > <file>\"", i+1)
+		}
+		fields := strings.Fields(parts[0])
+		output := strings.TrimSpace(parts[1])
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("line %d: missing generator", i+1)
+		}
+		if _, ok := config.Generators[fields[0]]; !ok {
+			return nil, fmt.Errorf("line %d: unknown generator %q", i+1, fields[0])
+		}
+		if output == "" || output != filepath.Base(output) || strings.ContainsAny(output, " \t") || output == "." || output == ".." {
+			return nil, fmt.Errorf("line %d: invalid output file %q", i+1, output)
+		}
+		if previous, ok := outputs[output]; ok {
+			return nil, fmt.Errorf("line %d: %s is already generated on line %d", i+1, output, previous)
+		}
+		outputs[output] = i + 1
+
+		commands = append(commands, generatorCommand{
+			line:      i + 1,
+			text:      text,
+			generator: fields[0],
+			args:      fields[1:],
+			output:    output,
+		})
+	}
+	return commands, nil
+}
+
+// 检查生成器源文件位于题目目录内
+func checkGeneratorConfig(config *model.GeneratorConfig) error {
+	for name, program := range config.Generators {
+		if name == "" || strings.ContainsAny(name, " \t>#") {
+			return fmt.Errorf("invalid generator name %q", name)
+		}
+		source := filepath.Clean(program.Source)
+		if program.Source == "" || filepath.IsAbs(source) || source == ".." || strings.HasPrefix(source, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid source %q of generator %s", program.Source, name)
+		}
+	}
+	_, err := parseGeneratorScript(config)
+	return err
+}
+
+// 随机种子只取决于生成器名与参数，修复生成器后同一命令仍使用相同的种子
+func generatorSeed(command generatorCommand) uint64 {
+	h := sha256.New()
+	h.Write([]byte(command.generator))
+	for _, arg := range command.args {
+		h.Write([]byte{0})
+		h.Write([]byte(arg))
+	}
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+// 缓存键：生成器源文件（及同目录的 testlib.h）、语言与参数的摘要
+func generatorKey(root string, program model.ProgramConfig, command generatorCommand) (string, error) {
+	sourcePath := filepath.Join(root, program.Source)
+	source, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(program.Lang))
+	h.Write([]byte{0})
+	h.Write(source)
+	h.Write([]byte{0})
+	if testlib, err := os.ReadFile(filepath.Join(filepath.Dir(sourcePath), "testlib.h")); err == nil {
+		h.Write(testlib)
+	}
+	for _, arg := range command.args {
+		h.Write([]byte{0})
+		h.Write([]byte(arg))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 上传生成器源文件或头文件
+func (s *ProblemService) UploadGeneratorFile(problemID model.ProblemId, name, srcPath string) error {
+	if _, err := s.problemRepo.GetByID(problemID); err != nil {
+		return err
+	}
+	return s.problemFilesystem.UploadGeneratorFile(problemID, name, srcPath)
+}
+
+// 保存测试数据生成配置
+func (s *ProblemService) UploadGeneratorConfig(problemID model.ProblemId, config *model.GeneratorConfig) error {
+	if _, err := s.problemRepo.GetByID(problemID); err != nil {
+		return err
+	}
+	if err := checkGeneratorConfig(config); err != nil {
+		return err
+	}
+	return s.problemFilesystem.SaveGeneratorConfig(problemID, config)
+}
+
+// 获取测试数据生成配置，尚未配置时返回 nil
+func (s *ProblemService) GetGeneratorConfig(problemID model.ProblemId) (*model.GeneratorConfig, error) {
+	return s.problemFilesystem.GetGeneratorConfig(problemID)
+}
+
+// 在沙箱中执行生成脚本，将生成的文件写入数据目录并更新配置文件的测试点列表。
+// 生成器与参数均未变化且文件仍存在时沿用已有文件，force 为 true 时全部重新生成
+func (s *ProblemService) GenerateTests(problemID model.ProblemId, force bool) (*model.TestGenerateReport, error) {
+	problem, err := s.problemRepo.GetByID(problemID)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.problemFilesystem.GetGeneratorConfig(problemID)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("problem %d has no generator config", problemID)
+	}
+	if err := checkGeneratorConfig(config); err != nil {
+		return nil, err
+	}
+	commands, err := parseGeneratorScript(config)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := s.problemFilesystem.GetGeneratedManifest(problemID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.TestGenerateReport{
+		Success: true,
+		Results: []model.TestGenerateResult{},
+	}
+	regenerated, err := s.runGeneratorScript(problemID, config, commands, manifest, force, report)
+	if err != nil {
+		return nil, err
+	}
+
+	// 重新生成的输入文件原有的答案已过时，同一脚本生成的答案除外
+	outputs := map[string]bool{}
+	for _, command := range commands {
+		outputs[command.output] = true
+	}
+	for _, input := range regenerated {
+		base := strings.TrimSuffix(input, filepath.Ext(input))
+		for _, answer := range []string{base + ".out", base + ".ans"} {
+			if outputs[answer] {
+				continue
+			}
+			if err := s.problemFilesystem.DeleteDataFile(problemID, answer); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.problemFilesystem.SaveGeneratedManifest(problemID, manifest); err != nil {
+		return nil, err
+	}
+
+	// 有标准程序时为缺少答案的输入文件重新生成答案，否则在报告中列出
+	missing, err := s.missingAnswers(problemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		if solution, err := s.problemFilesystem.GetStdSolution(problemID); err == nil && solution != nil {
+			answers, err := s.GenerateAnswers(problemID)
+			if err != nil {
+				return nil, fmt.Errorf("tests generated but answer generation failed: %v", err)
+			}
+			report.Answers = answers
+			if missing, err = s.missingAnswers(problemID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	report.MissingAnswers = missing
+
+	if err := s.problemFilesystem.MergeConfig(problem.ProblemCore); err != nil {
+		return nil, err
+	}
+	if err := s.problemRepo.UpdateTestdataStatus(problemID, true, false); err != nil {
+		return nil, err
+	}
+
+	// 新生成的输入文件同样需要通过校验
+	if report.Generated > 0 && s.problemFilesystem.HasValidator(problemID) {
+		validation, err := s.ValidateTestdata(problemID)
+		if err != nil {
+			return nil, fmt.Errorf("tests generated but validation failed: %v", err)
+		}
+		report.Validation = validation
+	}
+	return report, nil
+}
+
+// 依次执行生成脚本中的命令，返回重新生成的输入文件。
+// 执行失败的命令会删除原有的输出文件，避免评测时继续使用旧数据
+func (s *ProblemService) runGeneratorScript(problemID model.ProblemId, config *model.GeneratorConfig, commands []generatorCommand, manifest map[string]string, force bool, report *model.TestGenerateReport) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), generateTimeout)
+	defer cancel()
+	ctx = judge.WithFairKey(ctx, fmt.Sprintf("gen:%d", problemID))
+
+	sandbox, err := s.sandboxes.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer s.sandboxes.Release(sandbox)
+
+	root := s.problemFilesystem.GetProblemPath(problemID)
+	generators := map[string]*judge.Generator{}
+	var regenerated []string
+
+	for _, command := range commands {
+		result := model.TestGenerateResult{
+			Line:    command.line,
+			Command: command.text,
+			File:    command.output,
+		}
+		fail := func(err error) error {
+			result.Error = err.Error()
+			report.Success = false
+			delete(manifest, command.output)
+			return s.problemFilesystem.DeleteDataFile(problemID, command.output)
+		}
+
+		program := config.Generators[command.generator]
+		key, err := generatorKey(root, program, command)
+		if err != nil {
+			err = fail(err)
+		} else if !force && manifest[command.output] == key && s.problemFilesystem.DataFileExists(problemID, command.output) {
+			result.Cached = true
+			report.Cached++
+		} else if err = s.runGenerator(ctx, sandbox, problemID, root, program, command, generators, &result); err != nil {
+			err = fail(err)
+		} else {
+			manifest[command.output] = key
+			report.Generated++
+			if strings.HasSuffix(command.output, ".in") {
+				regenerated = append(regenerated, command.output)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		report.Results = append(report.Results, result)
+
+		// 沙箱请求超时后其余命令不再执行
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return regenerated, nil
+}
+
+// 数据目录中缺少答案文件的输入文件
+func (s *ProblemService) missingAnswers(problemID model.ProblemId) ([]string, error) {
+	files, err := s.problemFilesystem.ListInputFiles(problemID)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, file := range files {
+		base := strings.TrimSuffix(file, filepath.Ext(file))
+		if !s.problemFilesystem.DataFileExists(problemID, base+".out") && !s.problemFilesystem.DataFileExists(problemID, base+".ans") {
+			missing = append(missing, file)
+		}
+	}
+	return missing, nil
+}
+
+// 编译（同一生成器只编译一次）并运行生成器，将输出写入数据目录
+func (s *ProblemService) runGenerator(ctx context.Context, sandbox judge.Sandbox, problemID model.ProblemId, root string, program model.ProgramConfig, command generatorCommand, generators map[string]*judge.Generator, result *model.TestGenerateResult) error {
+	generator, ok := generators[command.generator]
+	if !ok {
+		var err error
+		generator, err = judge.NewGenerator(ctx, sandbox, root, program, s.programs)
+		if err != nil {
+			return err
+		}
+		generators[command.generator] = generator
+	}
+
+	outputId, timeUsed, err := generator.Generate(ctx, command.args, generatorSeed(command))
+	if err != nil {
+		return err
+	}
+	defer sandbox.DeleteFile(context.Background(), outputId)
+	result.Time = timeUsed
+
+	output, err := sandbox.OpenFile(ctx, outputId)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	return s.problemFilesystem.WriteDataFile(problemID, command.output, output)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"reisen-be/internal/model"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseGeneratorScript(t *testing.T) {
+	generators := map[string]model.ProgramConfig{
+		"gen":  {Source: "files/gen.cpp"},
+		"tree": {Source: "files/tree.cpp"},
+	}
+	tests := []struct {
+		name    string
+		script  string
+		want    []generatorCommand
+		wantErr string
+	}{
+		{
+			name:   "commands with comments and blank lines",
+			script: "# small tests\ngen 1 10 > 1.in\n\n  tree -n 5 >2.in  \n",
+			want: []generatorCommand{
+				{line: 2, text: "gen 1 10 > 1.in", generator: "gen", args: []string{"1", "10"}, output: "1.in"},
+				{line: 4, text: "tree -n 5 >2.in", generator: "tree", args: []string{"-n", "5"}, output: "2.in"},
+			},
+		},
+		{
+			name:   "generator without arguments",
+			script: "gen > 1.in",
+			want: []generatorCommand{
+				{line: 1, text: "gen > 1.in", generator: "gen", output: "1.in"},
+			},
+		},
+		{name: "empty script", script: "\n# nothing\n"},
+		{name: "missing redirection", script: "gen 1 10", wantErr: "line 1: expected"},
+		{name: "two redirections", script: "gen 1 > 1.in > 2.in", wantErr: "line 1: expected"},
+		{name: "missing generator", script: " > 1.in", wantErr: "line 1: missing generator"},
+		{name: "unknown generator", script: "gen 1 > 1.in\nrand 2 > 2.in", wantErr: "line 2: unknown generator \"rand\""},
+		{name: "missing output", script: "gen 1 >", wantErr: "invalid output file"},
+		{name: "output with path", script: "gen 1 > ../1.in", wantErr: "invalid output file"},
+		{name: "output with spaces", script: "gen 1 > 1 .in", wantErr: "invalid output file"},
+		{name: "dot output", script: "gen 1 > ..", wantErr: "invalid output file"},
+		{name: "duplicate output", script: "gen 1 > 1.in\ngen 2 > 1.in", wantErr: "line 2: 1.in is already generated on line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, err := parseGeneratorScript(&model.GeneratorConfig{Generators: generators, Script: tt.script})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.EqualFunc(commands, tt.want, func(a, b generatorCommand) bool {
+				return a.line == b.line && a.text == b.text && a.generator == b.generator &&
+					slices.Equal(a.args, b.args) && a.output == b.output
+			}) {
+				t.Fatalf("got %+v, want %+v", commands, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratorSeed(t *testing.T) {
+	seed := func(generator string, args ...string) uint64 {
+		return generatorSeed(generatorCommand{generator: generator, args: args})
+	}
+	if seed("gen", "1", "10") != seed("gen", "1", "10") {
+		t.Error("same command got different seeds")
+	}
+	if seed("gen", "1", "10") == seed("gen", "1", "11") {
+		t.Error("different arguments got the same seed")
+	}
+	if seed("gen", "1", "10") == seed("gen", "11", "0") {
+		t.Error("arguments are not separated in the seed")
+	}
+	if seed("gen", "1") == seed("tree", "1") {
+		t.Error("different generators got the same seed")
+	}
+}
@@ -0,0 +1,990 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"reisen-be/internal/filesystem"
+	"reisen-be/internal/model"
+	"reisen-be/internal/service/polygon"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导入的判分器、交互器、生成器等程序及其头文件在题目目录下的存放位置
+const polygonFilesDir = "files"
+
+var ErrPolygonCheckerUnsupported = errors.New("the checker has no testlib equivalent, switch to a custom checker before exporting")
+
+// 打开的 Polygon 题目包，文件名均相对 problem.xml 所在目录
+type polygonPackage struct {
+	files map[string]*zip.File
+}
+
+// 题目包可能整体位于一个子目录中，以最浅的 problem.xml 所在目录为根
+func openPolygonPackage(reader *zip.Reader) (*polygonPackage, error) {
+	prefix := ""
+	found := false
+	for _, file := range reader.File {
+		if path.Base(file.Name) != "problem.xml" {
+			continue
+		}
+		candidate := strings.TrimSuffix(file.Name, "problem.xml")
+		if !found || strings.Count(candidate, "/") < strings.Count(prefix, "/") {
+			prefix = candidate
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.New("problem.xml not found in package")
+	}
+
+	pkg := &polygonPackage{files: map[string]*zip.File{}}
+	for _, file := range reader.File {
+		if strings.HasSuffix(file.Name, "/") || !strings.HasPrefix(file.Name, prefix) {
+			continue
+		}
+		pkg.files[path.Clean(strings.TrimPrefix(file.Name, prefix))] = file
+	}
+	return pkg, nil
+}
+
+func (p *polygonPackage) has(name string) bool {
+	_, ok := p.files[path.Clean(name)]
+	return ok
+}
+
+func (p *polygonPackage) open(name string) (io.ReadCloser, error) {
+	file, ok := p.files[path.Clean(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in package", name)
+	}
+	return file.Open()
+}
+
+func (p *polygonPackage) read(name string) ([]byte, error) {
+	reader, err := p.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// 将包中的文件解压到 dst
+func (p *polygonPackage) extract(name, dst string) error {
+	reader, err := p.open(name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// 导入 Polygon 题目包，创建私有题目并导入题面、时空限制、测试数据、判分器、交互器、校验器与解答。
+// 包中只有生成命令的测试点用生成器生成，缺少答案文件时用主解答生成。导入失败时不保留题目
+func (s *ProblemService) ImportPolygon(zipPath string, provider model.UserId) (*model.ProblemImportReport, error) {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %v", err)
+	}
+	defer archive.Close()
+
+	pkg, err := openPolygonPackage(&archive.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pkg.read("problem.xml")
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := polygon.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	testset := descriptor.MainTestset()
+	if testset == nil || len(testset.Tests) == 0 {
+		return nil, errors.New("package has no tests")
+	}
+
+	report := &model.ProblemImportReport{Warnings: []string{}}
+	warn := func(format string, args ...any) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	problem := &model.Problem{
+		ProblemCore: model.ProblemCore{
+			Type:        model.ProblemTypeTraditional,
+			Status:      model.ProblemStatusPrivate,
+			LimitTime:   testset.TimeLimit,
+			LimitMemory: int(testset.MemoryLimit >> 20),
+			Title:       model.TitlesMap{},
+			Tags:        []model.TagId{},
+			Provider:    provider,
+		},
+	}
+	if descriptor.Assets.Interactor != nil {
+		problem.Type = model.ProblemTypeInteractive
+	}
+	problem.Statements = importPolygonStatements(pkg, descriptor, problem.Title, warn)
+	if len(problem.Title) == 0 {
+		problem.Title["en-US"] = descriptor.ShortName
+	}
+
+	if err := s.problemRepo.Create(problem); err != nil {
+		return nil, err
+	}
+	report.Problem = problem.ID
+
+	if err := s.importPolygonFiles(pkg, descriptor, testset, problem, report, warn); err != nil {
+		s.discardProblem(problem.ID)
+		return nil, err
+	}
+	return report, nil
+}
+
+// 导入测试数据、程序与解答，并写入评测配置
+func (s *ProblemService) importPolygonFiles(pkg *polygonPackage, descriptor *polygon.Problem, testset *polygon.Testset, problem *model.Problem, report *model.ProblemImportReport, warn func(string, ...any)) error {
+	root := s.problemFilesystem.GetProblemPath(problem.ID)
+
+	// 程序源文件与头文件按包中的相对路径解压到 files 目录下，返回相对题目目录的路径
+	extracted := map[string]string{}
+	sources := map[string]string{}
+	extract := func(file polygon.File) (string, error) {
+		name := path.Clean(file.Path)
+		if rel, ok := extracted[name]; ok {
+			return rel, nil
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return "", fmt.Errorf("invalid path %q", file.Path)
+		}
+		rel := path.Join(polygonFilesDir, strings.TrimPrefix(name, polygonFilesDir+"/"))
+		if other, ok := sources[rel]; ok {
+			return "", fmt.Errorf("%s and %s are both extracted to %s", other, name, rel)
+		}
+		if err := pkg.extract(name, filepath.Join(root, filepath.FromSlash(rel))); err != nil {
+			return "", err
+		}
+		extracted[name] = rel
+		sources[rel] = name
+		return rel, nil
+	}
+	for _, resource := range descriptor.Files.Resources {
+		if _, err := extract(resource); err != nil {
+			warn("resource %s: %v", resource.Path, err)
+		}
+	}
+
+	judgeConfig := model.JudgeConfig{
+		TimeLimit:      problem.LimitTime,
+		MemoryLimit:    problem.LimitMemory,
+		CheckerType:    "strict",
+		Type:           problem.Type,
+		InputFileName:  importPolygonFileName(descriptor.Judging.InputFile, "stdin", warn),
+		OutputFileName: importPolygonFileName(descriptor.Judging.OutputFile, "stdout", warn),
+	}
+
+	if checker := descriptor.Assets.Checker; checker != nil {
+		if builtin, ok := polygon.BuiltinChecker(checker.Name); ok {
+			judgeConfig.CheckerType = builtin.CheckerType
+			judgeConfig.Epsilon = builtin.Epsilon
+			judgeConfig.RelativeEpsilon = builtin.RelativeEpsilon
+		} else if checker.Source != nil {
+			source, err := extract(*checker.Source)
+			if err != nil {
+				return fmt.Errorf("checker: %v", err)
+			}
+			judgeConfig.CheckerType = "custom"
+			judgeConfig.Checker = &model.ProgramConfig{Source: source, Lang: polygon.LangFromType(checker.Source.Type)}
+		} else {
+			warn("checker %q has no source, using the default checker", checker.Name)
+		}
+	}
+	if interactor := descriptor.Assets.Interactor; interactor != nil {
+		source, err := extract(interactor.Source)
+		if err != nil {
+			return fmt.Errorf("interactor: %v", err)
+		}
+		judgeConfig.Interactor = &model.ProgramConfig{Source: source, Lang: polygon.LangFromType(interactor.Source.Type)}
+	}
+
+	// 可执行文件名（不含扩展名）即生成命令中的生成器名
+	executables := map[string]polygon.File{}
+	for _, executable := range descriptor.Files.Executables {
+		base := path.Base(executable.Source.Path)
+		executables[strings.TrimSuffix(base, path.Ext(base))] = executable.Source
+	}
+
+	// 测试点按顺序重新编号为 01.in/01.out 等
+	width := max(2, len(strconv.Itoa(len(testset.Tests))))
+	hasPoints := false
+	for _, test := range testset.Tests {
+		hasPoints = hasPoints || test.Points > 0
+	}
+	generators := map[string]model.ProgramConfig{}
+	var script []string
+	answers := map[string]string{} // 包中的答案文件，生成测试点会删除重新生成的输入文件的答案，之后再写入
+	missingAnswers := false
+	for i, test := range testset.Tests {
+		index := i + 1
+		name := fmt.Sprintf("%0*d", width, index)
+		testcase := model.TestCaseConfig{
+			ID:         index,
+			InputFile:  "tests/" + name + ".in",
+			OutputFile: "tests/" + name + ".out",
+			Score:      10,
+		}
+		if hasPoints {
+			testcase.Score = int(math.Round(test.Points))
+		}
+		judgeConfig.TestCases = append(judgeConfig.TestCases, testcase)
+
+		input := polygon.FormatPath(testset.InputPathPattern, index)
+		if pkg.has(input) {
+			if err := s.importPolygonDataFile(pkg, problem.ID, input, name+".in"); err != nil {
+				return fmt.Errorf("test %d: %v", index, err)
+			}
+		} else if fields := strings.Fields(test.Cmd); test.Method == "generated" && len(fields) > 0 {
+			if _, ok := generators[fields[0]]; !ok {
+				source, ok := executables[fields[0]]
+				if !ok {
+					return fmt.Errorf("test %d: unknown generator %q", index, fields[0])
+				}
+				rel, err := extract(source)
+				if err != nil {
+					return fmt.Errorf("generator %s: %v", fields[0], err)
+				}
+				generators[fields[0]] = model.ProgramConfig{Source: rel, Lang: polygon.LangFromType(source.Type)}
+			}
+			script = append(script, fmt.Sprintf("%s > %s.in", strings.Join(fields, " "), name))
+		} else {
+			return fmt.Errorf("test %d: input file %s not found", index, input)
+		}
+
+		answer := polygon.FormatPath(testset.AnswerPathPattern, index)
+		if pkg.has(answer) {
+			answers[answer] = name + ".out"
+		} else {
+			missingAnswers = true
+		}
+	}
+	judgeConfig.Subtasks = importPolygonGroups(testset, judgeConfig.TestCases)
+
+	// 校验器先于生成器导入，生成的输入文件同样需要校验
+	if validators := descriptor.Assets.Validators; len(validators) > 0 {
+		if len(validators) > 1 {
+			warn("only the first of %d validators is imported", len(validators))
+		}
+		source := validators[0].Source
+		if lang := polygon.LangFromType(source.Type); lang != "" && !strings.HasPrefix(string(lang), "cpp") {
+			warn("validator %s is not written in C++ and is not imported", source.Path)
+		} else {
+			rel, err := extract(source)
+			if err != nil {
+				return fmt.Errorf("validator: %v", err)
+			}
+			sourcePath := filepath.Join(root, filepath.FromSlash(rel))
+			testlibPath := filepath.Join(filepath.Dir(sourcePath), "testlib.h")
+			if _, err := os.Stat(testlibPath); err != nil {
+				testlibPath = ""
+			}
+			if err := s.problemFilesystem.UploadValidator(problem.ID, sourcePath, testlibPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 生成测试点时按数据目录更新配置中的测试点列表，保留其余设置
+	if err := s.problemFilesystem.SaveJudgeConfig(problem.ID, &judgeConfig); err != nil {
+		return err
+	}
+	if len(script) > 0 {
+		config := &model.GeneratorConfig{
+			Generators: generators,
+			Script:     strings.Join(script, "\n") + "\n",
+		}
+		if err := s.UploadGeneratorConfig(problem.ID, config); err != nil {
+			return fmt.Errorf("generator script: %v", err)
+		}
+		generated, err := s.GenerateTests(problem.ID, false)
+		if err != nil {
+			return err
+		}
+		report.Generated = generated
+		for _, result := range generated.Results {
+			if result.Error != "" {
+				return fmt.Errorf("failed to generate %s: %s", result.File, result.Error)
+			}
+		}
+	}
+	for answer, name := range answers {
+		if err := s.importPolygonDataFile(pkg, problem.ID, answer, name); err != nil {
+			return fmt.Errorf("answer %s: %v", answer, err)
+		}
+	}
+
+	for _, solution := range descriptor.Assets.Solutions {
+		name := path.Base(solution.Source.Path)
+		lang := polygon.LangFromType(solution.Source.Type)
+		if lang == "" {
+			warn("solution %s: unsupported language %s, skipped", name, solution.Source.Type)
+			continue
+		}
+		code, err := pkg.read(solution.Source.Path)
+		if err != nil {
+			warn("solution %s: %v", name, err)
+			continue
+		}
+		if solution.Tag == "main" {
+			if err := s.UploadStdSolution(problem.ID, &model.StdSolution{Lang: lang, Code: string(code)}); err != nil {
+				warn("main solution %s: %v", name, err)
+			}
+		}
+
+		expected, ok := polygon.ExpectedVerdicts(solution.Tag)
+		if !ok {
+			warn("solution %s: tag %q cannot be verified, skipped", name, solution.Tag)
+			continue
+		}
+		if err := s.jurySolutionRepo.Create(&model.JurySolution{
+			ProblemID: problem.ID,
+			Name:      name,
+			Lang:      lang,
+			Code:      string(code),
+			Expected:  expected,
+			Status:    model.JurySolutionStatusPending,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if missingAnswers {
+		if problem.Type == model.ProblemTypeInteractive {
+			return errors.New("answer files of interactive problems must be included in the package")
+		}
+		generated, err := s.GenerateAnswers(problem.ID)
+		if err != nil {
+			return fmt.Errorf("answer files are missing and cannot be generated: %v", err)
+		}
+		report.Answers = generated
+		if !generated.Success {
+			return errors.New("answer files are missing and the main solution failed to generate them")
+		}
+	}
+
+	// 生成测试点与答案时缺少答案的测试点会暂时移出配置，最后写回完整的评测配置
+	if err := s.problemFilesystem.SaveJudgeConfig(problem.ID, &judgeConfig); err != nil {
+		return err
+	}
+	if err := s.problemRepo.UpdateTestdataStatus(problem.ID, true, true); err != nil {
+		return err
+	}
+
+	if report.Generated != nil && report.Generated.Validation != nil {
+		report.Validation = report.Generated.Validation
+	} else if s.problemFilesystem.HasValidator(problem.ID) {
+		validation, err := s.ValidateTestdata(problem.ID)
+		if err != nil {
+			return err
+		}
+		report.Validation = validation
+	}
+	if report.Validation != nil && !report.Validation.Valid {
+		warn("testdata failed validation")
+	}
+	return nil
+}
+
+func (s *ProblemService) importPolygonDataFile(pkg *polygonPackage, problemID model.ProblemId, name, dst string) error {
+	reader, err := pkg.open(name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return s.problemFilesystem.WriteDataFile(problemID, dst, reader)
+}
+
+// 导入失败时删除已创建的题目及其文件
+func (s *ProblemService) discardProblem(problemID model.ProblemId) {
+	if solutions, err := s.jurySolutionRepo.ListByProblem(problemID); err == nil {
+		for _, solution := range solutions {
+			if err := s.jurySolutionRepo.Delete(solution.ID); err != nil {
+				log.Printf("Failed to delete jury solution %d: %v", solution.ID, err)
+			}
+		}
+	}
+	if err := os.RemoveAll(s.problemFilesystem.GetProblemPath(problemID)); err != nil {
+		log.Printf("Failed to remove files of problem %d: %v", problemID, err)
+	}
+	if err := s.problemRepo.Delete(problemID); err != nil {
+		log.Printf("Failed to delete problem %d: %v", problemID, err)
+	}
+}
+
+// Polygon 以 stdin/stdout 或空表示标准输入输出
+func importPolygonFileName(name, std string, warn func(string, ...any)) string {
+	if name == "" || name == std {
+		return ""
+	}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		warn("invalid file name %q, using %s instead", name, std)
+		return ""
+	}
+	return name
+}
+
+// 导入各语言的题面，标题写入 titles
+func importPolygonStatements(pkg *polygonPackage, descriptor *polygon.Problem, titles model.TitlesMap, warn func(string, ...any)) model.StatementsMap {
+	// 无法识别的语言原样作为语言代码，并给出警告
+	warned := map[string]bool{}
+	languageCode := func(language string) string {
+		code, ok := polygon.LanguageCode(language)
+		if !ok && !warned[language] {
+			warned[language] = true
+			warn("unknown statement language %q, kept as is", language)
+		}
+		return code
+	}
+
+	var languages []string
+	seen := map[string]bool{}
+	for _, name := range descriptor.Names {
+		titles[languageCode(name.Language)] = name.Value
+		if !seen[name.Language] {
+			seen[name.Language] = true
+			languages = append(languages, name.Language)
+		}
+	}
+	for _, statement := range descriptor.Statements {
+		if !seen[statement.Language] {
+			seen[statement.Language] = true
+			languages = append(languages, statement.Language)
+		}
+	}
+
+	statements := model.StatementsMap{}
+	for _, language := range languages {
+		props, err := readPolygonStatement(pkg, language)
+		if err != nil {
+			warn("statement in %s: %v", language, err)
+			continue
+		}
+		if props == nil {
+			continue
+		}
+
+		code := languageCode(language)
+		if titles[code] == "" && props.Name != "" {
+			titles[code] = props.Name
+		}
+		statement := model.Statement{
+			Legend:   props.Legend,
+			FormatI:  props.Input,
+			FormatO:  joinSections(props.Output, props.Interaction),
+			Note:     joinSections(props.Notes, props.Scoring),
+			Examples: []model.Example{},
+		}
+		for _, sample := range props.SampleTests {
+			statement.Examples = append(statement.Examples, model.Example{DataI: sample.Input, DataO: sample.Output})
+		}
+		statements[code] = statement
+	}
+	return statements
+}
+
+// 读取 problem-properties.json，没有时读取各段落的源文件，都没有时返回 nil
+func readPolygonStatement(pkg *polygonPackage, language string) (*polygon.StatementProperties, error) {
+	var props polygon.StatementProperties
+	if data, err := pkg.read(path.Join("statements", language, "problem-properties.json")); err == nil {
+		if err := json.Unmarshal(data, &props); err != nil {
+			return nil, fmt.Errorf("failed to parse problem-properties.json: %v", err)
+		}
+		return &props, nil
+	}
+
+	dir := path.Join("statement-sections", language)
+	if !pkg.has(path.Join(dir, "legend.tex")) {
+		return nil, nil
+	}
+	section := func(name string) string {
+		data, _ := pkg.read(path.Join(dir, name))
+		return strings.TrimSpace(string(data))
+	}
+	props.Name = section("name.tex")
+	props.Legend = section("legend.tex")
+	props.Input = section("input.tex")
+	props.Output = section("output.tex")
+	props.Interaction = section("interaction.tex")
+	props.Notes = section("notes.tex")
+	props.Scoring = section("scoring.tex")
+	for i := 1; pkg.has(path.Join(dir, fmt.Sprintf("example.%02d", i))); i++ {
+		example := fmt.Sprintf("example.%02d", i)
+		props.SampleTests = append(props.SampleTests, polygon.SampleTest{
+			Input:  section(example),
+			Output: section(example + ".a"),
+		})
+	}
+	return &props, nil
+}
+
+// 测试点分组转为子任务，未分组的测试点单独作为一个子任务
+func importPolygonGroups(testset *polygon.Testset, testcases []model.TestCaseConfig) []model.SubtaskConfig {
+	grouped := false
+	for _, test := range testset.Tests {
+		grouped = grouped || test.Group != ""
+	}
+	if !grouped {
+		return nil
+	}
+
+	groups := map[string]polygon.Group{}
+	var order []string
+	for _, group := range testset.Groups {
+		if _, ok := groups[group.Name]; !ok {
+			order = append(order, group.Name)
+		}
+		groups[group.Name] = group
+	}
+	members := map[string][]int{}
+	for i, test := range testset.Tests {
+		if _, ok := groups[test.Group]; !ok {
+			groups[test.Group] = polygon.Group{Name: test.Group}
+			order = append(order, test.Group)
+		}
+		members[test.Group] = append(members[test.Group], testcases[i].ID)
+	}
+
+	ids := map[string]int{}
+	for _, name := range order {
+		if len(members[name]) > 0 {
+			ids[name] = len(ids) + 1
+		}
+	}
+
+	var subtasks []model.SubtaskConfig
+	for _, name := range order {
+		id, ok := ids[name]
+		if !ok {
+			continue
+		}
+		group := groups[name]
+		subtask := model.SubtaskConfig{
+			ID:        id,
+			Policy:    model.SubtaskPolicySum,
+			TestCases: members[name],
+		}
+		if group.PointsPolicy == "complete-group" {
+			subtask.Policy = model.SubtaskPolicyAll
+		}
+		if group.Points > 0 {
+			subtask.Score = int(math.Round(group.Points))
+		} else {
+			for _, testcaseID := range members[name] {
+				subtask.Score += testcases[testcaseID-1].Score
+			}
+		}
+		for _, dependency := range group.Dependencies {
+			if dep, ok := ids[dependency.Group]; ok {
+				subtask.Dependencies = append(subtask.Dependencies, dep)
+			}
+		}
+		subtasks = append(subtasks, subtask)
+	}
+	return subtasks
+}
+
+// 将子任务导出为测试点组，返回各测试点所属的组名。同时属于多个子任务的测试点归入第一个
+func exportPolygonGroups(config *model.JudgeConfig) (map[int]string, []polygon.Group) {
+	testGroups := map[int]string{}
+	for _, subtask := range config.Subtasks {
+		for _, id := range subtask.TestCases {
+			if _, ok := testGroups[id]; !ok {
+				testGroups[id] = strconv.Itoa(subtask.ID)
+			}
+		}
+	}
+
+	var groups []polygon.Group
+	for _, subtask := range config.Subtasks {
+		group := polygon.Group{
+			Name:         strconv.Itoa(subtask.ID),
+			Points:       float64(subtask.Score),
+			PointsPolicy: "each-test",
+		}
+		if subtask.Policy == model.SubtaskPolicyAll || subtask.Policy == model.SubtaskPolicyMin {
+			group.PointsPolicy = "complete-group"
+		}
+		for _, dependency := range subtask.Dependencies {
+			group.Dependencies = append(group.Dependencies, polygon.Dependency{Group: strconv.Itoa(dependency)})
+		}
+		groups = append(groups, group)
+	}
+	return testGroups, groups
+}
+
+func joinSections(sections ...string) string {
+	var parts []string
+	for _, section := range sections {
+		if section = strings.TrimSpace(section); section != "" {
+			parts = append(parts, section)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// 将题目导出为 Polygon 题目包，返回临时 zip 文件路径
+func (s *ProblemService) ExportPolygon(problemID model.ProblemId) (*string, error) {
+	problem, err := s.problemRepo.GetByID(problemID)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.problemFilesystem.GetJudgeConfig(problemID)
+	if err != nil {
+		return nil, err
+	}
+
+	zipPath := filepath.Join(os.TempDir(), "problem_"+fmt.Sprint(problemID)+"_polygon.zip")
+	if err := s.writePolygonPackage(problem, config, zipPath); err != nil {
+		os.Remove(zipPath)
+		return nil, err
+	}
+	// 文件发送完成后删除临时文件
+	go func() {
+		time.Sleep(60 * time.Second)
+		os.Remove(zipPath)
+	}()
+	return &zipPath, nil
+}
+
+func (s *ProblemService) writePolygonPackage(problem *model.Problem, config *model.JudgeConfig, zipPath string) error {
+	file, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+
+	root := s.problemFilesystem.GetProblemPath(problem.ID)
+	added := map[string]bool{}
+	add := func(name string, content io.Reader) error {
+		if added[name] {
+			return fmt.Errorf("duplicate file %s in package", name)
+		}
+		added[name] = true
+		w, err := writer.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, content)
+		return err
+	}
+	addFile := func(name, src string) error {
+		reader, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return add(name, reader)
+	}
+
+	descriptor := &polygon.Problem{
+		Revision:  1,
+		ShortName: fmt.Sprintf("problem-%d", problem.ID),
+		Judging: polygon.Judging{
+			InputFile:  config.InputFileName,
+			OutputFile: config.OutputFileName,
+		},
+	}
+	for _, code := range sortedKeys(problem.Title) {
+		descriptor.Names = append(descriptor.Names, polygon.Name{
+			Language: polygon.PolygonLanguage(code),
+			Value:    problem.Title[code],
+		})
+	}
+
+	for _, code := range sortedKeys(problem.Statements) {
+		statement := problem.Statements[code]
+		language := polygon.PolygonLanguage(code)
+		props := &polygon.StatementProperties{
+			Name:        problem.Title[code],
+			Legend:      joinSections(statement.Background, statement.Legend),
+			Input:       statement.FormatI,
+			Output:      statement.FormatO,
+			Notes:       joinSections(statement.Note, statement.Hint),
+			InputFile:   "stdin",
+			OutputFile:  "stdout",
+			TimeLimit:   problem.LimitTime,
+			MemoryLimit: int64(problem.LimitMemory) << 20,
+			SampleTests: []polygon.SampleTest{},
+		}
+		if config.InputFileName != "" {
+			props.InputFile = config.InputFileName
+		}
+		if config.OutputFileName != "" {
+			props.OutputFile = config.OutputFileName
+		}
+		for _, example := range statement.Examples {
+			props.SampleTests = append(props.SampleTests, polygon.SampleTest{Input: example.DataI, Output: example.DataO})
+		}
+
+		data, err := json.MarshalIndent(props, "", "  ")
+		if err != nil {
+			return err
+		}
+		dir := path.Join("statements", language)
+		if err := add(path.Join(dir, "problem-properties.json"), strings.NewReader(string(data))); err != nil {
+			return err
+		}
+		if err := add(path.Join(dir, "problem.tex"), strings.NewReader(polygonStatementTex(props))); err != nil {
+			return err
+		}
+		descriptor.Statements = append(descriptor.Statements, polygon.Statement{
+			Charset:  "UTF-8",
+			Language: language,
+			Mathjax:  true,
+			Path:     path.Join(dir, "problem.tex"),
+			Type:     "application/x-tex",
+		})
+	}
+
+	testGroups, groups := exportPolygonGroups(config)
+	generatedCommands, generators := s.generatedTests(problem.ID)
+	usedGenerators := map[string]bool{}
+
+	testset := polygon.Testset{
+		Name:              "tests",
+		TimeLimit:         problem.LimitTime,
+		MemoryLimit:       int64(problem.LimitMemory) << 20,
+		TestCount:         len(config.TestCases),
+		InputPathPattern:  "tests/%02d",
+		AnswerPathPattern: "tests/%02d.a",
+	}
+	for i, testcase := range config.TestCases {
+		index := i + 1
+		if err := addFile(polygon.FormatPath(testset.InputPathPattern, index), filepath.Join(root, testcase.InputFile)); err != nil {
+			return err
+		}
+		if err := addFile(polygon.FormatPath(testset.AnswerPathPattern, index), filepath.Join(root, testcase.OutputFile)); err != nil {
+			return err
+		}
+
+		test := polygon.Test{
+			Method: "manual",
+			Points: float64(testcase.Score),
+			Group:  testGroups[testcase.ID],
+		}
+		if command, ok := generatedCommands[path.Base(testcase.InputFile)]; ok {
+			test.Method = "generated"
+			test.Cmd = command
+			usedGenerators[strings.Fields(command)[0]] = true
+		}
+		testset.Tests = append(testset.Tests, test)
+	}
+	testset.Groups = groups
+	descriptor.Judging.Testsets = []polygon.Testset{testset}
+
+	// 程序源文件导出到 files 目录，并附带首个找到的 testlib.h
+	testlibPath := ""
+	addProgram := func(name string, program model.ProgramConfig) (polygon.File, error) {
+		sourcePath := filepath.Join(root, program.Source)
+		source := polygon.File{
+			Path: path.Join(polygonFilesDir, name+polygon.SourceExt(program.Lang)),
+			Type: polygon.TypeFromLang(program.Lang),
+		}
+		if err := addFile(source.Path, sourcePath); err != nil {
+			return source, err
+		}
+		if testlibPath == "" {
+			candidate := filepath.Join(filepath.Dir(sourcePath), "testlib.h")
+			if _, err := os.Stat(candidate); err == nil {
+				testlibPath = candidate
+			}
+		}
+		return source, nil
+	}
+
+	if config.CheckerType == "custom" && config.Checker != nil {
+		source, err := addProgram("check", *config.Checker)
+		if err != nil {
+			return err
+		}
+		descriptor.Assets.Checker = &polygon.Checker{Type: "testlib", Source: &source}
+	} else if name := polygon.StdCheckerName(*config); name != "" {
+		descriptor.Assets.Checker = &polygon.Checker{Name: name, Type: "testlib"}
+	} else {
+		return fmt.Errorf("%w: %s", ErrPolygonCheckerUnsupported, config.CheckerType)
+	}
+	if problem.Type == model.ProblemTypeInteractive && config.Interactor != nil {
+		source, err := addProgram("interactor", *config.Interactor)
+		if err != nil {
+			return err
+		}
+		descriptor.Assets.Interactor = &polygon.Program{Source: source}
+	}
+	if s.problemFilesystem.HasValidator(problem.ID) {
+		source, err := addProgram("validator", model.ProgramConfig{Source: filesystem.ValidatorSource})
+		if err != nil {
+			return err
+		}
+		descriptor.Assets.Validators = []polygon.Program{{Source: source}}
+	}
+	for _, name := range sortedKeys(usedGenerators) {
+		source, err := addProgram(name, generators[name])
+		if err != nil {
+			return err
+		}
+		descriptor.Files.Executables = append(descriptor.Files.Executables, polygon.Executable{Source: source})
+	}
+	if testlibPath != "" {
+		resource := polygon.File{Path: path.Join(polygonFilesDir, "testlib.h"), Type: "h.g++"}
+		if err := addFile(resource.Path, testlibPath); err != nil {
+			return err
+		}
+		descriptor.Files.Resources = append(descriptor.Files.Resources, resource)
+	}
+
+	// 标准程序作为主解答，验题程序按预期结果标注
+	addSolution := func(name, tag string, lang model.CodeLangId, code string) error {
+		source := polygon.File{
+			Path: path.Join("solutions", name+polygon.SourceExt(lang)),
+			Type: polygon.TypeFromLang(lang),
+		}
+		if added[source.Path] {
+			return nil
+		}
+		if err := add(source.Path, strings.NewReader(code)); err != nil {
+			return err
+		}
+		descriptor.Assets.Solutions = append(descriptor.Assets.Solutions, polygon.Solution{Tag: tag, Source: source})
+		return nil
+	}
+	std, err := s.problemFilesystem.GetStdSolution(problem.ID)
+	if err != nil {
+		return err
+	}
+	if std != nil {
+		if err := addSolution("std", "main", std.Lang, std.Code); err != nil {
+			return err
+		}
+	}
+	solutions, err := s.jurySolutionRepo.ListByProblem(problem.ID)
+	if err != nil {
+		return err
+	}
+	for _, solution := range solutions {
+		name := polygonSolutionName(solution)
+		if added[path.Join("solutions", name+polygon.SourceExt(solution.Lang))] {
+			name = fmt.Sprintf("%s-%d", name, solution.ID)
+		}
+		if err := addSolution(name, polygon.SolutionTag(solution.Expected), solution.Lang, solution.Code); err != nil {
+			return err
+		}
+	}
+
+	data, err := descriptor.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := add("problem.xml", strings.NewReader(string(data))); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// 由生成脚本生成的测试点：文件名 → Polygon 生成命令，以及生成器配置
+func (s *ProblemService) generatedTests(problemID model.ProblemId) (map[string]string, map[string]model.ProgramConfig) {
+	commands := map[string]string{}
+	config, err := s.problemFilesystem.GetGeneratorConfig(problemID)
+	if err != nil || config == nil {
+		return commands, nil
+	}
+	manifest, err := s.problemFilesystem.GetGeneratedManifest(problemID)
+	if err != nil {
+		return commands, nil
+	}
+	parsed, err := parseGeneratorScript(config)
+	if err != nil {
+		return commands, nil
+	}
+	for _, command := range parsed {
+		if _, ok := manifest[command.output]; ok {
+			commands[command.output] = strings.Join(append([]string{command.generator}, command.args...), " ")
+		}
+	}
+	return commands, config.Generators
+}
+
+// 解答文件名取验题程序名中的字母、数字、- 与 _
+func polygonSolutionName(solution model.JurySolution) string {
+	name := strings.TrimSuffix(solution.Name, path.Ext(solution.Name))
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, name)
+	if name == "" || name == "std" {
+		return fmt.Sprintf("solution-%d", solution.ID)
+	}
+	return name
+}
+
+// 按 olymp.sty 的格式生成题面 TeX 源文件
+func polygonStatementTex(props *polygon.StatementProperties) string {
+	input, output := props.InputFile, props.OutputFile
+	if input == "stdin" {
+		input = "standard input"
+	}
+	if output == "stdout" {
+		output = "standard output"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\\begin{problem}{%s}{%s}{%s}{%g second}{%d megabytes}\n\n",
+		props.Name, input, output, float64(props.TimeLimit)/1000, props.MemoryLimit>>20)
+	b.WriteString(props.Legend + "\n\n")
+	b.WriteString("\\InputFile\n\n" + props.Input + "\n\n")
+	b.WriteString("\\OutputFile\n\n" + props.Output + "\n\n")
+	if len(props.SampleTests) > 0 {
+		b.WriteString("\\Examples\n\n")
+		for _, sample := range props.SampleTests {
+			fmt.Fprintf(&b, "\\exmp{%s\n}{%s\n}%%\n", strings.TrimRight(sample.Input, "\n"), strings.TrimRight(sample.Output, "\n"))
+		}
+		b.WriteString("\n")
+	}
+	if props.Notes != "" {
+		b.WriteString("\\Notes\n\n" + props.Notes + "\n\n")
+	}
+	b.WriteString("\\end{problem}\n")
+	return b.String()
+}
+
+func sortedKeys[M ~map[string]V, V any](m M) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"reflect"
+	"reisen-be/internal/model"
+	"reisen-be/internal/service/polygon"
+	"testing"
+)
+
+func TestImportPolygonGroups(t *testing.T) {
+	testcases := []model.TestCaseConfig{{ID: 1, Score: 10}, {ID: 2, Score: 20}, {ID: 3, Score: 30}, {ID: 4, Score: 40}}
+	tests := []struct {
+		name    string
+		testset polygon.Testset
+		want    []model.SubtaskConfig
+	}{
+		{
+			name:    "no groups",
+			testset: polygon.Testset{Tests: []polygon.Test{{}, {}, {}, {}}},
+		},
+		{
+			name: "groups with points and dependencies",
+			testset: polygon.Testset{
+				Tests: []polygon.Test{{Group: "samples"}, {Group: "small"}, {Group: "small"}, {Group: "large"}},
+				Groups: []polygon.Group{
+					{Name: "samples", PointsPolicy: "each-test"},
+					{Name: "small", Points: 30, PointsPolicy: "complete-group", Dependencies: []polygon.Dependency{{Group: "samples"}}},
+					{Name: "large", Points: 70, PointsPolicy: "each-test", Dependencies: []polygon.Dependency{{Group: "small"}, {Group: "unused"}}},
+					{Name: "unused", Points: 5},
+				},
+			},
+			want: []model.SubtaskConfig{
+				{ID: 1, Score: 10, Policy: model.SubtaskPolicySum, TestCases: []int{1}},
+				{ID: 2, Score: 30, Policy: model.SubtaskPolicyAll, TestCases: []int{2, 3}, Dependencies: []int{1}},
+				{ID: 3, Score: 70, Policy: model.SubtaskPolicySum, TestCases: []int{4}, Dependencies: []int{2}},
+			},
+		},
+		{
+			name: "undeclared group and ungrouped tests",
+			testset: polygon.Testset{
+				Tests:  []polygon.Test{{Group: "1"}, {Group: "2"}, {}, {Group: "1"}},
+				Groups: []polygon.Group{{Name: "2", Points: 25}},
+			},
+			want: []model.SubtaskConfig{
+				{ID: 1, Score: 25, Policy: model.SubtaskPolicySum, TestCases: []int{2}},
+				{ID: 2, Score: 50, Policy: model.SubtaskPolicySum, TestCases: []int{1, 4}},
+				{ID: 3, Score: 30, Policy: model.SubtaskPolicySum, TestCases: []int{3}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := importPolygonGroups(&tt.testset, testcases)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 导出为测试点组后再导入，得到相同的子任务
+func TestPolygonGroupsRoundTrip(t *testing.T) {
+	config := &model.JudgeConfig{
+		TestCases: []model.TestCaseConfig{{ID: 1, Score: 10}, {ID: 2, Score: 20}, {ID: 3, Score: 30}, {ID: 4, Score: 40}},
+		Subtasks: []model.SubtaskConfig{
+			{ID: 1, Score: 30, Policy: model.SubtaskPolicySum, TestCases: []int{1, 2}},
+			{ID: 2, Score: 30, Policy: model.SubtaskPolicyAll, TestCases: []int{3}, Dependencies: []int{1}},
+			{ID: 3, Score: 40, Policy: model.SubtaskPolicySum, TestCases: []int{4}, Dependencies: []int{1, 2}},
+		},
+	}
+
+	testGroups, groups := exportPolygonGroups(config)
+	testset := polygon.Testset{Groups: groups}
+	for _, testcase := range config.TestCases {
+		testset.Tests = append(testset.Tests, polygon.Test{Points: float64(testcase.Score), Group: testGroups[testcase.ID]})
+	}
+
+	got := importPolygonGroups(&testset, config.TestCases)
+	if !reflect.DeepEqual(got, config.Subtasks) {
+		t.Fatalf("got %+v, want %+v", got, config.Subtasks)
+	}
+}
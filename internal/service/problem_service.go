@@ -1,27 +1,55 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reisen-be/internal/config"
 	"reisen-be/internal/filesystem"
 	"reisen-be/internal/model"
 	"reisen-be/internal/query"
 	"reisen-be/internal/repository"
+	"reisen-be/internal/service/judge"
+	"time"
 )
 
+const (
+	validateTimeout = 5 * time.Minute  // 校验一道题目全部输入文件的最长时间
+	generateTimeout = 10 * time.Minute // 用标准程序生成全部答案文件的最长时间
+)
+
+var ErrJurySolutionsUnverified = errors.New("jury solutions do not match their expected verdicts on the current testdata")
+
 type ProblemService struct {
 	problemListQuery  *query.ProblemListQuery
 	problemRepo       *repository.ProblemRepository
 	problemFilesystem *filesystem.ProblemFilesystem
+	jurySolutionRepo  *repository.JurySolutionRepository
+	sandboxes         *judge.SandboxPool
+	compiler          *judge.Compiler
+	runner            *judge.Runner
+	programs          *judge.ProgramCache
 }
 
 func NewProblemService(
 	problemListQuery *query.ProblemListQuery,
 	problemRepo *repository.ProblemRepository,
 	problemFilesystem *filesystem.ProblemFilesystem,
+	jurySolutionRepo *repository.JurySolutionRepository,
+	sandboxes *judge.SandboxPool,
+	languages *config.LanguageRegistry,
 ) *ProblemService {
+	compiler := judge.NewCompiler(languages, nil)
 	return &ProblemService{
 		problemListQuery:  problemListQuery,
 		problemRepo:       problemRepo,
 		problemFilesystem: problemFilesystem,
+		jurySolutionRepo:  jurySolutionRepo,
+		sandboxes:         sandboxes,
+		compiler:          compiler,
+		runner:            judge.NewRunner(languages, nil),
+		programs:          judge.NewProgramCache(compiler),
 	}
 }
 
@@ -30,9 +58,42 @@ func (s *ProblemService) CreateProblem(problem *model.Problem) error {
 }
 
 func (s *ProblemService) UpdateProblem(problem *model.Problem) error {
+	// 公开题目前，验题程序须在当前测试数据上全部符合预期
+	if problem.Status == model.ProblemStatusPublic {
+		existing, err := s.problemRepo.GetByID(problem.ID)
+		if err != nil {
+			return err
+		}
+		if existing.Status != model.ProblemStatusPublic {
+			if err := s.checkJurySolutions(problem.ProblemCore); err != nil {
+				return err
+			}
+		}
+	}
 	return s.problemRepo.Update(problem)
 }
 
+// 检查全部验题程序在当前测试数据与评测配置（以 problem 的时空限制为准）上验证过且符合预期
+func (s *ProblemService) checkJurySolutions(problem model.ProblemCore) error {
+	solutions, err := s.jurySolutionRepo.ListByProblem(problem.ID)
+	if err != nil {
+		return err
+	}
+	if len(solutions) == 0 {
+		return nil
+	}
+	fingerprint, err := s.problemFilesystem.GetTestdataFingerprint(problem)
+	if err != nil {
+		return ErrJurySolutionsUnverified
+	}
+	for _, solution := range solutions {
+		if solution.Matched == nil || !*solution.Matched || solution.Fingerprint != fingerprint {
+			return ErrJurySolutionsUnverified
+		}
+	}
+	return nil
+}
+
 func (s *ProblemService) GetProblem(id model.ProblemId) (*model.Problem, error) {
 	return s.problemRepo.GetByID(id)
 }
@@ -49,22 +110,38 @@ func (s *ProblemService) DeleteProblem(id model.ProblemId) error {
 	return s.problemRepo.Delete(id)
 }
 
-func (s *ProblemService) UploadTestdata(problemID model.ProblemId, filePath string) error {
+// 上传测试数据，题目配置了校验器时返回输入文件的校验报告
+func (s *ProblemService) UploadTestdata(problemID model.ProblemId, filePath string) (*model.ValidationReport, error) {
 	problem, err := s.problemRepo.GetByID(problemID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// 上传测试数据
 	if err := s.problemFilesystem.UploadTestdata(problemID, filePath); err != nil {
-		return err
+		return nil, err
 	}
-	// 生成配置文件
-	if err := s.problemFilesystem.GenerateConfig(problem.ProblemCore); err != nil {
-		return err
+	// 按新的数据更新配置文件的测试点列表，保留判分器、子任务等其余配置
+	if err := s.problemFilesystem.MergeConfig(problem.ProblemCore); err != nil {
+		return nil, err
 	}
 
 	// 更新数据库记录
-	return s.problemRepo.UpdateTestdataStatus(problemID, true, false)
+	if err := s.problemRepo.UpdateTestdataStatus(problemID, true, false); err != nil {
+		return nil, err
+	}
+
+	if !s.problemFilesystem.HasValidator(problemID) {
+		// 旧的校验结果已不对应当前数据
+		if err := s.problemFilesystem.DeleteValidationReport(problemID); err != nil {
+			return nil, err
+		}
+		return nil, s.problemRepo.UpdateTestdataValidity(problemID, false)
+	}
+	report, err := s.ValidateTestdata(problemID)
+	if err != nil {
+		return nil, fmt.Errorf("testdata uploaded but validation failed: %v", err)
+	}
+	return report, nil
 }
 
 func (s *ProblemService) DownloadTestdata(problemID model.ProblemId) (*string, error) {
@@ -75,6 +152,12 @@ func (s *ProblemService) DeleteTestdata(problemID model.ProblemId) error {
 	if err := s.problemFilesystem.DeleteTestdata(problemID); err != nil {
 		return err
 	}
+	if err := s.problemFilesystem.DeleteValidationReport(problemID); err != nil {
+		return err
+	}
+	if err := s.problemRepo.UpdateTestdataValidity(problemID, false); err != nil {
+		return err
+	}
 	return s.problemRepo.UpdateTestdataStatus(problemID, false, false)
 }
 
@@ -90,3 +173,222 @@ func (s *ProblemService) UploadConfig(problemID model.ProblemId, config *model.T
 func (s *ProblemService) GetConfig(problemID model.ProblemId) (*model.TestdataConfig, error) {
 	return s.problemFilesystem.GetConfig(problemID)
 }
+
+// 上传校验器，testlibPath 非空时使用上传的 testlib.h
+func (s *ProblemService) UploadValidator(problemID model.ProblemId, sourcePath, testlibPath string) error {
+	if _, err := s.problemRepo.GetByID(problemID); err != nil {
+		return err
+	}
+	return s.problemFilesystem.UploadValidator(problemID, sourcePath, testlibPath)
+}
+
+// 在沙箱中用校验器检查全部输入文件，保存校验报告并更新题目的数据状态
+func (s *ProblemService) ValidateTestdata(problemID model.ProblemId) (*model.ValidationReport, error) {
+	if !s.problemFilesystem.HasValidator(problemID) {
+		return nil, fmt.Errorf("problem %d has no validator", problemID)
+	}
+	files, err := s.problemFilesystem.ListInputFiles(problemID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+	defer cancel()
+	ctx = judge.WithFairKey(ctx, fmt.Sprintf("validate:%d", problemID))
+
+	sandbox, err := s.sandboxes.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer s.sandboxes.Release(sandbox)
+
+	report := &model.ValidationReport{
+		ValidatedAt: time.Now(),
+		Valid:       true,
+		Results:     []model.ValidationResult{},
+	}
+
+	root := s.problemFilesystem.GetProblemPath(problemID)
+	validator, err := judge.NewValidator(ctx, sandbox, root, model.ProgramConfig{Source: filesystem.ValidatorSource}, s.programs)
+	if err != nil {
+		// 校验器编译失败时数据视为未通过校验
+		report.Valid = false
+		report.Message = err.Error()
+	} else {
+		dataPath := s.problemFilesystem.GetDataPath(problemID)
+		for _, file := range files {
+			valid, message, err := validator.Validate(ctx, filepath.Join(dataPath, file))
+			if err != nil {
+				return nil, err
+			}
+			report.Results = append(report.Results, model.ValidationResult{
+				File:    file,
+				Valid:   valid,
+				Message: message,
+			})
+			report.Valid = report.Valid && valid
+		}
+	}
+
+	if err := s.problemFilesystem.SaveValidationReport(problemID, report); err != nil {
+		return nil, err
+	}
+	if err := s.problemRepo.UpdateTestdataValidity(problemID, !report.Valid); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// 获取最近一次的校验报告，尚未校验时返回 nil
+func (s *ProblemService) GetValidationReport(problemID model.ProblemId) (*model.ValidationReport, error) {
+	return s.problemFilesystem.GetValidationReport(problemID)
+}
+
+// 保存标准程序
+func (s *ProblemService) UploadStdSolution(problemID model.ProblemId, solution *model.StdSolution) error {
+	if _, err := s.problemRepo.GetByID(problemID); err != nil {
+		return err
+	}
+	if _, err := s.runner.Limits(&model.JudgeTask{Submission: model.Submission{SubmissionCore: model.SubmissionCore{Lang: solution.Lang}}}); err != nil {
+		return err
+	}
+	return s.problemFilesystem.SaveStdSolution(problemID, solution)
+}
+
+// 获取标准程序，尚未上传时返回 nil
+func (s *ProblemService) GetStdSolution(problemID model.ProblemId) (*model.StdSolution, error) {
+	return s.problemFilesystem.GetStdSolution(problemID)
+}
+
+// 在沙箱中以题目的时空限制运行标准程序，为全部输入文件生成答案并更新配置文件的测试点列表。
+// 只要有一个输入文件未能正常运行，就不写入任何答案文件
+func (s *ProblemService) GenerateAnswers(problemID model.ProblemId) (*model.AnswerGenerateReport, error) {
+	problem, err := s.problemRepo.GetByID(problemID)
+	if err != nil {
+		return nil, err
+	}
+	solution, err := s.problemFilesystem.GetStdSolution(problemID)
+	if err != nil {
+		return nil, err
+	}
+	if solution == nil {
+		return nil, fmt.Errorf("problem %d has no standard solution", problemID)
+	}
+	files, err := s.problemFilesystem.ListInputFiles(problemID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 沿用现有配置中的文件输入输出设置
+	judgeConfig := model.JudgeConfig{}
+	if config, err := s.problemFilesystem.GetJudgeConfig(problemID); err == nil {
+		judgeConfig.InputFileName = config.InputFileName
+		judgeConfig.OutputFileName = config.OutputFileName
+		judgeConfig.OutputLimit = config.OutputLimit
+	}
+	judgeConfig.TimeLimit = problem.LimitTime
+	judgeConfig.MemoryLimit = problem.LimitMemory
+
+	task := &model.JudgeTask{
+		Submission: model.Submission{
+			SubmissionCore: model.SubmissionCore{
+				ProblemID: problemID,
+				Lang:      solution.Lang,
+			},
+			Code: solution.Code,
+		},
+		Config: judgeConfig,
+	}
+	limits, err := s.runner.Limits(task)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), generateTimeout)
+	defer cancel()
+	ctx = judge.WithFairKey(ctx, fmt.Sprintf("std:%d", problemID))
+
+	sandbox, err := s.sandboxes.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer s.sandboxes.Release(sandbox)
+
+	report := &model.AnswerGenerateReport{
+		Lang:    solution.Lang,
+		Limits:  limits,
+		Results: []model.AnswerGenerateResult{},
+	}
+
+	fileId, compileInfo, err := s.compiler.Compile(ctx, sandbox, task)
+	report.CompileInfo = compileInfo
+	if err != nil {
+		// 没有编译信息说明请求沙箱失败，而非编译错误
+		if compileInfo == nil {
+			return nil, err
+		}
+		return report, nil
+	}
+	defer s.compiler.DeleteFile(context.Background(), sandbox, fileId)
+
+	// 全部运行完成后再写入答案，期间输出缓存在沙箱中
+	outputIds := make([]string, len(files))
+	defer func() {
+		for _, outputId := range outputIds {
+			if outputId != "" {
+				sandbox.DeleteFile(context.Background(), outputId)
+			}
+		}
+	}()
+
+	dataPath := s.problemFilesystem.GetDataPath(problemID)
+	success := true
+	for i, file := range files {
+		testResult, outputId, err := s.runner.RunSolution(ctx, sandbox, task, fileId, filepath.Join(dataPath, file))
+		if err != nil {
+			return nil, err
+		}
+		outputIds[i] = outputId
+
+		result := model.AnswerGenerateResult{
+			File:    file,
+			Verdict: testResult.Verdict,
+			Time:    *testResult.Time,
+			Memory:  *testResult.Memory,
+		}
+		if testResult.Checker != nil {
+			result.Message = *testResult.Checker
+		}
+		report.Results = append(report.Results, result)
+		report.MaxTime = max(report.MaxTime, result.Time)
+		report.MaxMemory = max(report.MaxMemory, result.Memory)
+		success = success && result.Verdict == model.VerdictAC
+	}
+	if !success {
+		return report, nil
+	}
+
+	for i, file := range files {
+		if err := s.writeAnswer(ctx, sandbox, problemID, file, outputIds[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.problemFilesystem.MergeConfig(problem.ProblemCore); err != nil {
+		return nil, err
+	}
+	if err := s.problemRepo.UpdateTestdataStatus(problemID, true, false); err != nil {
+		return nil, err
+	}
+	report.Success = true
+	return report, nil
+}
+
+// 从沙箱中取回标准程序的输出，写为答案文件
+func (s *ProblemService) writeAnswer(ctx context.Context, sandbox judge.Sandbox, problemID model.ProblemId, inputFile, outputId string) error {
+	output, err := sandbox.OpenFile(ctx, outputId)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	return s.problemFilesystem.WriteAnswer(problemID, inputFile, output)
+}
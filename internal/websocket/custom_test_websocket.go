@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reisen-be/internal/model"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 单条消息的写入超时，避免慢速连接阻塞自测结果的推送
+const customTestWriteWait = 10 * time.Second
+
+// 自测结果推送，结果只保留一段时间，不写入数据库
+type CustomTestWs struct {
+	clients      map[string]map[*client]bool
+	lastMessages map[string]model.CustomTestResult
+	mu           sync.Mutex
+	retention    time.Duration
+}
+
+func NewCustomTestWs(retention time.Duration) *CustomTestWs {
+	return &CustomTestWs{
+		clients:      make(map[string]map[*client]bool),
+		lastMessages: make(map[string]model.CustomTestResult),
+		retention:    retention,
+	}
+}
+
+func (wm *CustomTestWs) HandleConnection(w http.ResponseWriter, r *http.Request, id string) error {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return fmt.Errorf("failed to upgrade connection: %v", err)
+	}
+	defer conn.Close()
+
+	c := &client{
+		conn:      conn,
+		closeChan: make(chan struct{}),
+	}
+
+	// 注册连接并立即发送最后一条消息（如果有），发送完成前之后的推送等待在 c.mu 上，保证顺序
+	c.mu.Lock()
+	wm.mu.Lock()
+	if _, ok := wm.clients[id]; !ok {
+		wm.clients[id] = make(map[*client]bool)
+	}
+	wm.clients[id][c] = true
+	lastMsg, ok := wm.lastMessages[id]
+	wm.mu.Unlock()
+
+	if ok {
+		wm.write(c, lastMsg)
+	}
+	c.mu.Unlock()
+
+	// 保持连接直到客户端断开
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			wm.mu.Lock()
+			delete(wm.clients[id], c)
+			if len(wm.clients[id]) == 0 {
+				delete(wm.clients, id)
+			}
+			wm.mu.Unlock()
+			close(c.closeChan)
+			return nil
+		}
+	}
+}
+
+// 推送自测结果，最终结果在保留时长后清除
+func (wm *CustomTestWs) Broadcast(id string, message model.CustomTestResult, final bool) {
+	wm.mu.Lock()
+	wm.lastMessages[id] = message
+	clients := make([]*client, 0, len(wm.clients[id]))
+	for c := range wm.clients[id] {
+		clients = append(clients, c)
+	}
+	wm.mu.Unlock()
+
+	// 依次同步发送，保证同一连接收到的消息与推送顺序一致
+	for _, c := range clients {
+		wm.send(c, message)
+	}
+
+	if final {
+		time.AfterFunc(wm.retention, func() {
+			wm.mu.Lock()
+			delete(wm.lastMessages, id)
+			wm.mu.Unlock()
+		})
+	}
+}
+
+func (wm *CustomTestWs) send(c *client, message model.CustomTestResult) {
+	select {
+	case <-c.closeChan:
+		return
+	default:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		wm.write(c, message)
+	}
+}
+
+// 写入一条消息，调用方需持有 c.mu
+func (wm *CustomTestWs) write(c *client, message model.CustomTestResult) {
+	msg, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(customTestWriteWait))
+	if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		c.conn.Close()
+	}
+}
+
+// 以关闭帧断开所有连接
+func (wm *CustomTestWs) Close() {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	for _, clients := range wm.clients {
+		for c := range clients {
+			closeClient(c)
+		}
+	}
+}
@@ -35,6 +35,9 @@ type SubmissionWs struct {
 	lastMessages    map[model.SubmissionId]model.Submission
 	lastMessagesMux sync.Mutex
 	throttle        time.Duration
+	done            chan struct{} // 通知广播协程退出
+	stopped         chan struct{} // 广播协程已退出
+	closeOnce       sync.Once
 }
 
 func NewSubmissionWs(throttle time.Duration) *SubmissionWs {
@@ -43,6 +46,8 @@ func NewSubmissionWs(throttle time.Duration) *SubmissionWs {
 		broadcastChan: make(chan broadcastMessage, 100),
 		lastMessages:  make(map[model.SubmissionId]model.Submission),
 		throttle:      throttle,
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
 	}
 
 	go ws.broadcastWorker()
@@ -104,82 +109,128 @@ func (wm *SubmissionWs) Broadcast(submissionId model.SubmissionId, message model
 	wm.lastMessages[submissionId] = message
 	wm.lastMessagesMux.Unlock()
 
-	// 发送到广播通道
-	wm.broadcastChan <- broadcastMessage{
+	// 发送到广播通道，已关闭时丢弃
+	select {
+	case wm.broadcastChan <- broadcastMessage{
 		submissionId: submissionId,
 		message:      message,
+	}:
+	case <-wm.done:
 	}
 }
 
 func (wm *SubmissionWs) broadcastWorker() {
+	defer close(wm.stopped)
+
 	ticker := time.NewTicker(wm.throttle)
 	defer ticker.Stop()
 
 	pending := make(map[model.SubmissionId]struct{})
-	var pendingMux sync.Mutex
 
 	for {
 		select {
 		case msg := <-wm.broadcastChan:
-			pendingMux.Lock()
 			pending[msg.submissionId] = struct{}{}
-			pendingMux.Unlock()
 
 		case <-ticker.C:
+			wm.flush(pending, false)
+			pending = make(map[model.SubmissionId]struct{})
 
-			pendingMux.Lock()
-			submissionIds := make([]model.SubmissionId, 0, len(pending))
-			for id := range pending {
-				submissionIds = append(submissionIds, id)
+		case <-wm.done:
+			// 发送尚在通道中与待发送的消息后退出
+		drain:
+			for {
+				select {
+				case msg := <-wm.broadcastChan:
+					pending[msg.submissionId] = struct{}{}
+				default:
+					break drain
+				}
 			}
-			pending = make(map[model.SubmissionId]struct{})
-			pendingMux.Unlock()
+			wm.flush(pending, true)
+			return
+		}
+	}
+}
 
-			for _, id := range submissionIds {
-				wm.lastMessagesMux.Lock()
-				message, ok := wm.lastMessages[id]
-				wm.lastMessagesMux.Unlock()
+// 向订阅者发送各评测记录的最后一条消息，wait 为真时等待发送完成
+func (wm *SubmissionWs) flush(pending map[model.SubmissionId]struct{}, wait bool) {
+	var wg sync.WaitGroup
+	for id := range pending {
+		wm.lastMessagesMux.Lock()
+		message, ok := wm.lastMessages[id]
+		wm.lastMessagesMux.Unlock()
 
-				if !ok {
-					continue
-				}
+		if !ok {
+			continue
+		}
 
-				wm.clientsMux.RLock()
-				clients, ok := wm.clients[id]
-				if !ok {
-					wm.clientsMux.RUnlock()
-					continue
-				}
+		wm.clientsMux.RLock()
+		clients, ok := wm.clients[id]
+		if !ok {
+			wm.clientsMux.RUnlock()
+			continue
+		}
 
-				msg, err := json.Marshal(message)
-				if err != nil {
-					wm.clientsMux.RUnlock()
-					continue
-				}
+		msg, err := json.Marshal(message)
+		if err != nil {
+			wm.clientsMux.RUnlock()
+			continue
+		}
 
-				for c := range clients {
-					go func(c *client) {
-						select {
-						case <-c.closeChan:
-							return
-						default:
-							c.mu.Lock()
-							defer c.mu.Unlock()
-
-							if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-								c.conn.Close()
-								wm.clientsMux.Lock()
-								delete(clients, c)
-								if len(clients) == 0 {
-									delete(wm.clients, id)
-								}
-								wm.clientsMux.Unlock()
-							}
+		for c := range clients {
+			wg.Add(1)
+			go func(c *client) {
+				defer wg.Done()
+				select {
+				case <-c.closeChan:
+					return
+				default:
+					c.mu.Lock()
+					defer c.mu.Unlock()
+
+					if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+						c.conn.Close()
+						wm.clientsMux.Lock()
+						delete(clients, c)
+						if len(clients) == 0 {
+							delete(wm.clients, id)
 						}
-					}(c)
+						wm.clientsMux.Unlock()
+					}
 				}
-				wm.clientsMux.RUnlock()
-			}
+			}(c)
 		}
+		wm.clientsMux.RUnlock()
+	}
+	if wait {
+		wg.Wait()
 	}
-}
\ No newline at end of file
+}
+
+// 发送剩余消息后以关闭帧断开所有连接，之后不应再调用 Broadcast
+func (wm *SubmissionWs) Close() {
+	wm.closeOnce.Do(func() {
+		close(wm.done)
+		<-wm.stopped
+
+		wm.clientsMux.Lock()
+		defer wm.clientsMux.Unlock()
+
+		for _, clients := range wm.clients {
+			for c := range clients {
+				closeClient(c)
+			}
+		}
+	})
+}
+
+// 发送关闭帧并断开连接
+func closeClient(c *client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	c.conn.Close()
+}